@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// allocDelta is the heap allocation done between two runtime.MemStats snapshots.
+type allocDelta struct {
+	mallocs uint64 // heap objects allocated
+	bytes   uint64 // heap bytes allocated, freed or not
+}
+
+// String formats the delta for the -alloc-stats report.
+func (d allocDelta) String() string {
+	return fmt.Sprintf("allocations: mallocs=%d bytes=%d", d.mallocs, d.bytes)
+}
+
+// measureAllocs runs fn and returns the heap allocations made while it ran. The counters
+// are process-wide, so concurrent work outside fn is counted too.
+func measureAllocs(fn func() error) (allocDelta, error) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err := fn()
+	runtime.ReadMemStats(&after)
+
+	return allocDelta{
+		mallocs: after.Mallocs - before.Mallocs,
+		bytes:   after.TotalAlloc - before.TotalAlloc,
+	}, err
+}
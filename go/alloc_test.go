@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestMeasureAllocs tests that allocations made by fn are counted and its error returned.
+func TestMeasureAllocs(t *testing.T) {
+	var sink [][]byte
+	allocs, err := measureAllocs(func() error {
+		for range 100 {
+			sink = append(sink, make([]byte, 1024))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, sink, 100)
+	require.GreaterOrEqual(t, allocs.mallocs, uint64(100))
+	require.GreaterOrEqual(t, allocs.bytes, uint64(100*1024))
+
+	errBoom := errors.New("boom")
+	_, err = measureAllocs(func() error { return errBoom })
+	require.ErrorIs(t, err, errBoom)
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_AllocStats tests that -alloc-stats reports populated allocation deltas after a run.
+func TestRun_AllocStats(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"-alloc-stats", file.Name()}, nil, &stdout, &stderr))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n\n", stdout.String())
+
+	match := regexp.MustCompile(`allocations: mallocs=(\d+) bytes=(\d+)\n`).FindStringSubmatch(stderr.String())
+	require.NotNil(t, match, stderr.String())
+	for _, value := range match[1:] {
+		n, err := strconv.ParseUint(value, 10, 64)
+		require.NoError(t, err)
+		require.Positive(t, n)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// asciiFastPath reports whether lines can take the -assume-ascii path: it only handles plain
+// `station;temp` aggregation, so options that transform lines or need extra state opt out.
+func (o *options) asciiFastPath() bool {
+	return o.assumeASCII &&
+		!o.byHour && !o.unitSuffix && !o.kelvin && !o.tenths && !o.clamp && !o.tempFirst && !o.jsonl && o.sep2 == 0 &&
+		o.allowlist == nil && o.blocklist == nil && o.sep != ' ' && !o.utf8Replace && !o.sanitize &&
+		o.sampleRate == 0 && o.topK == 0 && o.approxCardinality == 0 && o.quantize == 0 && o.limitStations == 0 &&
+		!o.sortedInput && !o.needsHistogram() && !o.bySign && !o.firstLast && !o.cv && !o.kahan && !o.checkOrder && o.hotLines == 0
+}
+
+// processASCIILine is processLine for input promised to be ASCII with -assume-ascii. It works
+// on the mapped bytes directly: no string is allocated for the line, and the station key is
+// only copied when it is first seen, as tuples are updated in place in p.asciiStats until
+// flushASCII. Violating the promise is undefined behavior.
+func (p *processor) processASCIILine(line []byte) error {
+	p.lines++
+
+	sep := bytes.LastIndexByte(line, p.opts.separator())
+	if sep == -1 {
+		return p.skipOrFail(fmt.Errorf("could not parse line: %s", line))
+	}
+	if err := checkTempDigits(line[sep+1:], p.opts.maxTempDigits); err != nil {
+		return p.skipOrFail(err)
+	}
+	temperature, err := parseTenths(line[sep+1:])
+	if err != nil {
+		return p.skipOrFail(err)
+	}
+	if math.IsNaN(temperature) {
+		p.nans++ // see processLine
+		return nil
+	}
+	if outOfRange(temperature) {
+		return p.skipOrFail(fmt.Errorf("temperature out of range: %s", line))
+	}
+
+	station := line[:sep]
+	if tup, exists := p.asciiStats[string(station)]; exists { // the conversion doesn't allocate for a lookup
+		tup[0] = math.Min(tup[0], temperature)
+		tup[1] += temperature
+		tup[2]++
+		tup[3] = math.Max(tup[3], temperature)
+		return nil
+	}
+	p.asciiStats[string(station)] = &[4]float64{temperature, temperature, 1, temperature}
+	return nil
+}
+
+// flushASCII folds the tuples collected by processASCIILine into p.stats.
+func (p *processor) flushASCII() {
+	for station, tup := range p.asciiStats {
+		existing, exists := p.stats[station]
+		if !exists {
+			p.stats[station] = *tup
+			continue
+		}
+		p.stats[station] = [4]float64{
+			math.Min(existing[0], tup[0]), // min
+			existing[1] + tup[1],          // sum
+			existing[2] + tup[2],          // count
+			math.Max(existing[3], tup[3]), // max
+		}
+	}
+	clear(p.asciiStats)
+}
+
+// skipOrFail returns err, or counts the line as skipped with -ignore-errors.
+func (p *processor) skipOrFail(err error) error {
+	if p.opts.ignoreErrors {
+		p.skipped++
+		return nil
+	}
+	return err
+}
+
+// parseTenths parses the 1BRC temperature format `-?d?d.d` without going through a string,
+// and falls back to strconv for anything else.
+func parseTenths(b []byte) (float64, error) {
+	neg := len(b) > 0 && b[0] == '-'
+	digits := b
+	if neg {
+		digits = b[1:]
+	}
+
+	var tenths int
+	switch {
+	case len(digits) == 3 && isDigit(digits[0]) && digits[1] == '.' && isDigit(digits[2]):
+		tenths = int(digits[0]-'0')*10 + int(digits[2]-'0')
+	case len(digits) == 4 && isDigit(digits[0]) && isDigit(digits[1]) && digits[2] == '.' && isDigit(digits[3]):
+		tenths = int(digits[0]-'0')*100 + int(digits[1]-'0')*10 + int(digits[3]-'0')
+	default:
+		temperature, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return 0, fmt.Errorf("could not parse temperature: %w", err)
+		}
+		return temperature, nil
+	}
+
+	if neg {
+		tenths = -tenths
+	}
+	return float64(tenths) / 10, nil
+}
+
+// checkTempDigits fails if the temperature field has more than limit digits, so absurd
+// values such as 99999999999999.9 are rejected before they are parsed (0 = no limit).
+func checkTempDigits[T string | []byte](field T, limit int) error {
+	if limit == 0 || len(field) <= limit {
+		return nil // too short to have more digits
+	}
+	digits := 0
+	for i := range len(field) {
+		if isDigit(field[i]) {
+			digits++
+		}
+	}
+	if digits > limit {
+		return fmt.Errorf("temperature %q has %d digits, more than -max-temp-digits %d", field, digits, limit)
+	}
+	return nil
+}
+
+func isDigit(b byte) bool { return '0' <= b && b <= '9' }
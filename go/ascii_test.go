@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestParseTenths tests the fast temperature parser, including the strconv fallback.
+func TestParseTenths(t *testing.T) {
+	tests := map[string]float64{
+		"0.0": 0, "1.5": 1.5, "-1.5": -1.5, "12.3": 12.3, "-99.9": -99.9, // fast path
+		"5": 5, "-0.25": -0.25, "1e1": 10, // fallback
+	}
+	for input, expected := range tests {
+		got, err := parseTenths([]byte(input))
+		require.NoError(t, err, input)
+		require.Equal(t, expected, got, input)
+	}
+
+	for _, input := range []string{"", "-", "1.x", "abc"} {
+		_, err := parseTenths([]byte(input))
+		require.Error(t, err, input)
+	}
+}
+
+// TestCheckTempDigits tests the digit guard: signs and decimal points don't count.
+func TestCheckTempDigits(t *testing.T) {
+	require.NoError(t, checkTempDigits("-12.5", 3))
+	require.NoError(t, checkTempDigits([]byte("99999999999999.9"), 0), "0 is unlimited")
+	require.EqualError(t, checkTempDigits([]byte("99999999999999.9"), 4),
+		`temperature "99999999999999.9" has 15 digits, more than -max-temp-digits 4`)
+	require.Error(t, checkTempDigits("123.4", 3))
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestProcessFile_AssumeASCII tests that the fast path gives the same results as the default one.
+func TestProcessFile_AssumeASCII(t *testing.T) {
+	path := writeASCIIFixture(t, t.TempDir(), 10_000)
+
+	standard := newProcessor(options{})
+	require.NoError(t, standard.processFile(path))
+	fast := newProcessor(options{assumeASCII: true})
+	require.NoError(t, fast.processFile(path))
+
+	require.Equal(t, formatOutput(standard.stats), formatOutput(fast.stats))
+	require.Equal(t, standard.lines, fast.lines)
+}
+
+// TestProcessFile_MaxTempDigits tests that an overlong temperature fails with a clear
+// error on both paths rather than being parsed.
+func TestProcessFile_MaxTempDigits(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;99999999999999.9\n")
+	defer cleanupTestFile(t, file)
+
+	for _, assumeASCII := range []bool{false, true} {
+		err := newProcessor(options{assumeASCII: assumeASCII, maxTempDigits: 4}).processFile(file.Name())
+		require.ErrorContains(t, err, "has 15 digits, more than -max-temp-digits 4", "assume-ascii=%t", assumeASCII)
+
+		p := newProcessor(options{assumeASCII: assumeASCII, maxTempDigits: 4, ignoreErrors: true})
+		require.NoError(t, p.processFile(file.Name()))
+		require.Equal(t, int64(1), p.skipped)
+		require.Equal(t, "{Hamburg=12.0/12.0/12.0}", formatOutput(p.stats))
+	}
+}
+
+// TestProcessFile_AssumeASCIIErrors tests malformed lines on the fast path.
+func TestProcessFile_AssumeASCIIErrors(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin\nOslo;120.0\n")
+	defer cleanupTestFile(t, file)
+
+	err := newProcessor(options{assumeASCII: true}).processFile(file.Name())
+	require.EqualError(t, err, "could not parse line: Berlin")
+
+	p := newProcessor(options{assumeASCII: true, ignoreErrors: true})
+	require.NoError(t, p.processFile(file.Name()))
+	require.Equal(t, int64(2), p.skipped)
+	require.Equal(t, "{Hamburg=12.0/12.0/12.0}", formatOutput(p.stats))
+}
+
+// BenchmarkProcessFile compares the default parser with the -assume-ascii fast path.
+func BenchmarkProcessFile(b *testing.B) {
+	path := writeASCIIFixture(b, b.TempDir(), 200_000)
+	info, err := os.Stat(path)
+	require.NoError(b, err)
+
+	for name, opts := range map[string]options{"default": {}, "assume-ascii": {assumeASCII: true}} {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(info.Size())
+			b.ReportAllocs()
+			for b.Loop() {
+				if err := newProcessor(opts).processFile(path); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// -------------------------------------------- Test Helper Functions --------------------------------------------
+
+// writeASCIIFixture writes n pseudo-random 1BRC lines over a few hundred stations to dir.
+func writeASCIIFixture(tb testing.TB, dir string, n int) string {
+	tb.Helper()
+	rng := newRand(1)
+	var data strings.Builder
+	for range n {
+		fmt.Fprintf(&data, "Station %03d;%.1f\n", rng.IntN(400), float64(rng.IntN(1999)-999)/10)
+	}
+
+	path := filepath.Join(dir, "measurements.txt")
+	require.NoError(tb, os.WriteFile(path, []byte(data.String()), 0o644))
+	return path
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+)
+
+// benchCommand is the subcommand that times repeated runs over a file.
+const benchCommand = "bench"
+
+// runBench implements `bench -runs 10 file.txt`: it processes the file -runs times,
+// discarding the results, and prints the min, median, p95 and max wall-clock durations.
+func runBench(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet(benchCommand, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	runs := fs.Int("runs", 10, "number of `N` timed repetitions")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *runs < 1 {
+		return errors.New("-runs must be at least 1")
+	}
+	path := defaultFilePath
+	if fs.NArg() > 0 {
+		path = fs.Arg(0)
+	}
+
+	timings, err := timeRuns(path, *runs)
+	if err != nil {
+		return &inputError{path: path, err: err}
+	}
+	stats := summarizeTimings(timings)
+	_, err = fmt.Fprintf(stdout, "runs=%d min=%s median=%s p95=%s max=%s\n",
+		len(timings), stats.min, stats.median, stats.p95, stats.max)
+	return err
+}
+
+// timeRuns processes the file at path n times and returns the duration of each run.
+// time.Since reads the monotonic clock, so the timings are immune to wall-clock jumps.
+func timeRuns(path string, n int) ([]time.Duration, error) {
+	timings := make([]time.Duration, 0, n)
+	for range n {
+		start := time.Now()
+		if err := newProcessor(options{}).processFile(path); err != nil {
+			return nil, err
+		}
+		timings = append(timings, time.Since(start))
+	}
+	return timings, nil
+}
+
+// timingStats summarizes the durations of repeated runs.
+type timingStats struct {
+	min, median, p95, max time.Duration
+}
+
+// summarizeTimings computes the timing stats of at least one duration.
+func summarizeTimings(timings []time.Duration) timingStats {
+	sorted := slices.Clone(timings)
+	slices.Sort(sorted)
+	return timingStats{
+		min:    sorted[0],
+		median: percentile(sorted, 50),
+		p95:    percentile(sorted, 95),
+		max:    sorted[len(sorted)-1],
+	}
+}
+
+// percentile returns the nearest-rank p-th percentile (0 < p <= 100) of sorted.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestSummarizeTimings tests the nearest-rank percentiles over unsorted timings.
+func TestSummarizeTimings(t *testing.T) {
+	var timings []time.Duration
+	for i := 20; i >= 1; i-- {
+		timings = append(timings, time.Duration(i)*time.Millisecond)
+	}
+
+	require.Equal(t, timingStats{
+		min:    1 * time.Millisecond,
+		median: 10 * time.Millisecond,
+		p95:    19 * time.Millisecond,
+		max:    20 * time.Millisecond,
+	}, summarizeTimings(timings))
+	require.Equal(t, 20*time.Millisecond, timings[0], "the input isn't reordered")
+
+	single := summarizeTimings([]time.Duration{time.Second})
+	require.Equal(t, timingStats{time.Second, time.Second, time.Second, time.Second}, single)
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_Bench tests 3 repetitions on a tiny file: every stat is populated and ordered.
+func TestRun_Bench(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\n")
+	defer cleanupTestFile(t, file)
+
+	timings, err := timeRuns(file.Name(), 3)
+	require.NoError(t, err)
+	require.Len(t, timings, 3)
+	stats := summarizeTimings(timings)
+	require.Positive(t, stats.min)
+	require.LessOrEqual(t, stats.min, stats.median)
+	require.LessOrEqual(t, stats.median, stats.p95)
+	require.LessOrEqual(t, stats.p95, stats.max)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"bench", "-runs", "3", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Regexp(t, regexp.MustCompile(`^runs=3 min=\S+ median=\S+ p95=\S+ max=\S+\n$`), stdout.String())
+
+	require.EqualError(t, run([]string{"bench", "-runs", "0", file.Name()}, nil, &stdout, &bytes.Buffer{}), "-runs must be at least 1")
+}
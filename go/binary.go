@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+)
+
+// The binary stats format is a compact, fixed-layout dump of a Result in little endian:
+//
+//	[uint32 stationCount]
+//	stationCount × [uint16 nameLen][name][int16 minTenths][int16 maxTenths][int64 sumTenths][int64 count]
+//
+// Temperatures are stored as integer tenths of a degree, the precision of the input, so
+// a result read back with ReadBinaryStats merges like the original as long as every
+// reading had at most one decimal.
+
+// binaryStatsFixedSize is the size of a station record without its name.
+const binaryStatsFixedSize = 2 + 2 + 8 + 8
+
+// WriteBinaryStats writes the result in the binary stats format, sorted by station name.
+func WriteBinaryStats(w io.Writer, result Result) error {
+	if len(result) > math.MaxUint32 {
+		return fmt.Errorf("could not write binary stats: too many stations: %d", len(result))
+	}
+
+	bw := bufio.NewWriter(w)
+	buf := binary.LittleEndian.AppendUint32(nil, uint32(len(result)))
+	if _, err := bw.Write(buf); err != nil {
+		return fmt.Errorf("could not write binary stats: %w", err)
+	}
+	for _, station := range slices.Sorted(maps.Keys(result)) {
+		if len(station) > math.MaxUint16 {
+			return fmt.Errorf("could not write binary stats: station name too long: %d bytes", len(station))
+		}
+		s := result[station]
+		buf = buf[:0]
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(len(station)))
+		buf = append(buf, station...)
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(toTenths(s.Min)))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(toTenths(s.Max)))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(toTenths(s.Sum)))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(s.Count))
+
+		if _, err := bw.Write(buf); err != nil {
+			return fmt.Errorf("could not write binary stats: %w", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("could not write binary stats: %w", err)
+	}
+	return nil
+}
+
+// ReadBinaryStats reads a result written by WriteBinaryStats.
+func ReadBinaryStats(r io.Reader) (Result, error) {
+	br := bufio.NewReader(r)
+
+	var header [4]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, fmt.Errorf("could not read binary stats header: %w", err)
+	}
+	count := binary.LittleEndian.Uint32(header[:])
+
+	result := make(Result, min(count, 1<<16))
+	var fixed [binaryStatsFixedSize]byte
+	for i := range count {
+		if _, err := io.ReadFull(br, fixed[:2]); err != nil {
+			return nil, fmt.Errorf("could not read binary stats station %d: %w", i, unexpectedEOF(err))
+		}
+		name := make([]byte, binary.LittleEndian.Uint16(fixed[:2]))
+		if _, err := io.ReadFull(br, name); err != nil {
+			return nil, fmt.Errorf("could not read binary stats station %d: %w", i, unexpectedEOF(err))
+		}
+		if _, err := io.ReadFull(br, fixed[:]); err != nil {
+			return nil, fmt.Errorf("could not read binary stats station %d: %w", i, unexpectedEOF(err))
+		}
+
+		s := Stats{
+			Min:   float64(int16(binary.LittleEndian.Uint16(fixed[0:2]))) / 10,
+			Max:   float64(int16(binary.LittleEndian.Uint16(fixed[2:4]))) / 10,
+			Sum:   float64(int64(binary.LittleEndian.Uint64(fixed[4:12]))) / 10,
+			Count: int64(binary.LittleEndian.Uint64(fixed[12:20])),
+		}
+		station := string(name)
+		result[station] = result[station].merge(s)
+	}
+
+	return result, nil
+}
+
+// toTenths converts a temperature to the nearest integer number of tenths.
+func toTenths(v float64) int64 {
+	return int64(math.Round(v * 10))
+}
+
+// unexpectedEOF turns a clean EOF in the middle of the stream into io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestBinaryStats_RoundTrip tests that WriteBinaryStats and ReadBinaryStats preserve results
+// with one-decimal readings, in the documented fixed layout.
+func TestBinaryStats_RoundTrip(t *testing.T) {
+	original := Result{
+		"Berlin":   {Min: -3.2, Sum: 45.1, Count: 3, Max: 25.0},
+		"Semi;Way": {Min: 1.5, Sum: 3.0, Count: 2, Max: 1.5},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteBinaryStats(&buf, original))
+	require.Equal(t, 4+2*(2+binaryStatsFixedSize)+len("Berlin")+len("Semi;Way"), buf.Len())
+	require.Equal(t, []byte{2, 0, 0, 0, 6, 0, 'B', 'e', 'r', 'l', 'i', 'n', 0xe0, 0xff}, buf.Bytes()[:14], "count, name and min of -32 tenths")
+
+	decoded, err := ReadBinaryStats(&buf)
+	require.NoError(t, err)
+	require.True(t, original.Equal(decoded, 1e-9), original.Diff(decoded, 1e-9))
+}
+
+// TestBinaryStats_MergeAfterReload tests that reloaded results merge like the originals.
+func TestBinaryStats_MergeAfterReload(t *testing.T) {
+	first := Result{"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0}}
+	second := Result{
+		"Hamburg": {Min: -1.5, Sum: 13.5, Count: 2, Max: 15.0},
+		"Oslo":    {Min: -5.0, Sum: -5.0, Count: 1, Max: -5.0},
+	}
+
+	reloaded := make(Result)
+	for _, result := range []Result{first, second} {
+		var buf bytes.Buffer
+		require.NoError(t, WriteBinaryStats(&buf, result))
+		decoded, err := ReadBinaryStats(&buf)
+		require.NoError(t, err)
+		reloaded.Merge(decoded)
+	}
+
+	want := Result{
+		"Hamburg": {Min: -1.5, Sum: 33.5, Count: 4, Max: 15.0},
+		"Oslo":    {Min: -5.0, Sum: -5.0, Count: 1, Max: -5.0},
+	}
+	require.True(t, want.Equal(reloaded, 1e-9), want.Diff(reloaded, 1e-9))
+}
+
+// TestReadBinaryStats_Truncated tests that a cut-off dump is rejected.
+func TestReadBinaryStats_Truncated(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteBinaryStats(&buf, Result{"Berlin": {Min: 1.0, Sum: 1.0, Count: 1, Max: 1.0}}))
+
+	_, err := ReadBinaryStats(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, err = ReadBinaryStats(bytes.NewReader(nil))
+	require.ErrorContains(t, err, "could not read binary stats header")
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Strategies of -bins-mode.
+const (
+	binsModeWidth = "width" // bins of equal temperature width between the lowest and highest mean
+	binsModeCount = "count" // bins of (nearly) equal numbers of stations, by ascending mean
+)
+
+// assignBins buckets the stations into n bins by their mean, numbered from 0 for the
+// coldest. Stations without readings, seeded by -emit-empty, get no bin.
+//
+// With binsModeWidth the range of the means is split into n equal intervals, the highest
+// mean falling into the last one. With binsModeCount the stations are ranked by mean, ties
+// by name, and the i-th of k stations goes to bin i*n/k, so bin sizes differ by one at most.
+func assignBins(stats map[string][4]float64, n int, mode string) map[string]int {
+	stations := SortedStats(stats)
+	stations = slices.DeleteFunc(stations, func(s StationStat) bool { return s.Count == 0 })
+	bins := make(map[string]int, len(stations))
+	if len(stations) == 0 {
+		return bins
+	}
+
+	if mode == binsModeCount {
+		slices.SortFunc(stations, func(a, b StationStat) int {
+			return cmp.Or(cmp.Compare(a.Mean, b.Mean), strings.Compare(a.Name, b.Name))
+		})
+		for i, s := range stations {
+			bins[s.Name] = i * n / len(stations)
+		}
+		return bins
+	}
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, s := range stations {
+		lo, hi = math.Min(lo, s.Mean), math.Max(hi, s.Mean)
+	}
+	width := (hi - lo) / float64(n)
+	for _, s := range stations {
+		bin := 0
+		if width > 0 {
+			bin = min(int((s.Mean-lo)/width), n-1)
+		}
+		bins[s.Name] = bin
+	}
+	return bins
+}
+
+// formatBinCounts formats the -bins summary line with the number of stations per bin,
+// e.g. `bins: 0=3 1=0 2=5`.
+func formatBinCounts(bins map[string]int, n int) string {
+	counts := make([]int, n)
+	for _, bin := range bins {
+		counts[bin]++
+	}
+	var summary strings.Builder
+	summary.WriteString("bins:")
+	for bin, count := range counts {
+		fmt.Fprintf(&summary, " %d=%d", bin, count)
+	}
+	return summary.String()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestAssignBins tests both strategies on stations with a known spread of means.
+func TestAssignBins(t *testing.T) {
+	stats := map[string][4]float64{
+		"A": {-12, -20, 2, -8}, // mean -10
+		"B": {0, 0, 1, 0},
+		"C": {1, 1, 1, 1},
+		"D": {9, 9, 1, 9},
+		"E": {10, 10, 1, 10},
+		"F": {}, // an -emit-empty placeholder
+	}
+
+	tests := []struct {
+		n    int
+		mode string
+		want map[string]int
+	}{
+		{2, binsModeWidth, map[string]int{"A": 0, "B": 1, "C": 1, "D": 1, "E": 1}},
+		{4, binsModeWidth, map[string]int{"A": 0, "B": 2, "C": 2, "D": 3, "E": 3}},
+		{2, binsModeCount, map[string]int{"A": 0, "B": 0, "C": 0, "D": 1, "E": 1}},
+		{4, binsModeCount, map[string]int{"A": 0, "B": 0, "C": 1, "D": 2, "E": 3}},
+	}
+	for _, tc := range tests {
+		require.Equal(t, tc.want, assignBins(stats, tc.n, tc.mode), "n=%d mode=%s", tc.n, tc.mode)
+	}
+
+	same := map[string][4]float64{"A": {5, 5, 1, 5}, "B": {5, 10, 2, 5}}
+	require.Equal(t, map[string]int{"A": 0, "B": 0}, assignBins(same, 3, binsModeWidth), "equal means share the first bin")
+	require.Empty(t, assignBins(map[string][4]float64{}, 3, binsModeCount))
+}
+
+// TestFormatBinCounts tests the per-bin summary, including empty bins.
+func TestFormatBinCounts(t *testing.T) {
+	require.Equal(t, "bins: 0=1 1=0 2=2", formatBinCounts(map[string]int{"A": 0, "B": 2, "C": 2}, 3))
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_Bins tests that -bins annotates every station with its bin and prints the
+// stations per bin after the results.
+func TestRun_Bins(t *testing.T) {
+	file := createTestFile(t, "A;-10.0\nB;0.0\nC;1.0\nD;9.0\nE;10.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-bins", "2", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{A=-10.0/-10.0/-10.0 bin=0, B=0.0/0.0/0.0 bin=1, C=1.0/1.0/1.0 bin=1, D=9.0/9.0/9.0 bin=1, E=10.0/10.0/10.0 bin=1}\n\nbins: 0=1 1=4\n", stdout.String())
+
+	stdout.Reset()
+	require.NoError(t, run([]string{"-bins", "2", "-bins-mode", "count", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{A=-10.0/-10.0/-10.0 bin=0, B=0.0/0.0/0.0 bin=0, C=1.0/1.0/1.0 bin=0, D=9.0/9.0/9.0 bin=1, E=10.0/10.0/10.0 bin=1}\n\nbins: 0=3 1=2\n", stdout.String())
+
+	err := run([]string{"-bins", "2", "-bins-mode", "quantile", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.EqualError(t, err, `-bins-mode must be width or count, got "quantile"`)
+}
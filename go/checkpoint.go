@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// A checkpoint records how far a -checkpoint run got through its input file: a header line
+// with the byte offset of the first line not yet aggregated, followed by the stats so far
+// in the intermediate format.
+//
+//	offset=123456
+//	station;min;sum;count;max
+//
+// It is replaced atomically, so a crash while writing leaves the previous checkpoint intact.
+
+// defaultCheckpointEvery is how many bytes are scanned between two checkpoints.
+const defaultCheckpointEvery = 256 << 20
+
+// linesSuffix marks a -checkpoint-interval counted in lines rather than bytes.
+const linesSuffix = "lines"
+
+// checkpointer periodically saves the progress of processFile and restores it with -resume.
+type checkpointer struct {
+	path       string
+	every      int   // bytes scanned between two checkpoints, unless everyLines is set
+	everyLines int64 // lines scanned between two checkpoints, 0 to count bytes
+	offset     int   // offset of the last checkpoint saved or loaded, where a resumed scan starts
+	lines      int64 // lines seen when the last checkpoint was saved
+	saved      int   // checkpoints saved by this run
+}
+
+// parseCheckpointInterval parses a -checkpoint-interval: a byte count, or a line count
+// with the "lines" suffix, e.g. "100000lines".
+func parseCheckpointInterval(value string) (every int, everyLines int64, err error) {
+	if count, ok := strings.CutSuffix(value, linesSuffix); ok {
+		everyLines, err = strconv.ParseInt(count, 10, 64)
+		if err != nil || everyLines < 1 {
+			return 0, 0, fmt.Errorf("invalid -checkpoint-interval %q: want a positive byte count or N%s", value, linesSuffix)
+		}
+		return 0, everyLines, nil
+	}
+	every, err = strconv.Atoi(value)
+	if err != nil || every < 1 {
+		return 0, 0, fmt.Errorf("invalid -checkpoint-interval %q: want a positive byte count or N%s", value, linesSuffix)
+	}
+	return every, 0, nil
+}
+
+// due reports whether a checkpoint is due at offset, after lines lines were seen.
+func (c *checkpointer) due(offset int, lines int64) bool {
+	if c.everyLines > 0 {
+		return lines-c.lines >= c.everyLines
+	}
+	return offset-c.offset >= c.every
+}
+
+// save replaces the checkpoint with the stats of every line before offset.
+func (c *checkpointer) save(offset int, stats map[string][4]float64) error {
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create checkpoint: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }() // no-op once renamed
+
+	w := bufio.NewWriter(tmp)
+	_, err = fmt.Fprintf(w, "offset=%d\n", offset)
+	if err == nil {
+		err = WriteIntermediate(w, FromMap(stats))
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("could not write checkpoint: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("could not replace checkpoint: %w", err)
+	}
+
+	c.offset = offset
+	c.saved++
+	return nil
+}
+
+// load reads the checkpoint into stats and sets c.offset to resume from. A missing
+// checkpoint leaves both untouched, so the run starts from the beginning.
+func (c *checkpointer) load(stats map[string][4]float64) error {
+	file, err := os.Open(c.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not open checkpoint: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	r := bufio.NewReader(file)
+	header, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("could not read checkpoint: %w", err)
+	}
+	value, ok := strings.CutPrefix(strings.TrimSuffix(header, "\n"), "offset=")
+	if !ok {
+		return fmt.Errorf("invalid checkpoint header: %q", header)
+	}
+	offset, err := strconv.Atoi(value)
+	if err != nil || offset < 0 {
+		return fmt.Errorf("invalid checkpoint offset: %q", value)
+	}
+
+	result, err := ReadIntermediate(r)
+	if err != nil {
+		return err
+	}
+	maps.Copy(stats, result.ToMap())
+
+	c.offset = offset
+	return nil
+}
+
+// remove deletes the checkpoint once the run has completed, so it isn't resumed again.
+func (c *checkpointer) remove() error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not remove checkpoint: %w", err)
+	}
+	return nil
+}
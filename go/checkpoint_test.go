@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestCheckpointer_RoundTrip tests that a saved checkpoint loads back with its offset and stats.
+func TestCheckpointer_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.checkpoint")
+	stats := map[string][4]float64{"Hamburg": {8.0, 20.0, 2.0, 12.0}}
+
+	require.NoError(t, (&checkpointer{path: path}).save(26, stats))
+
+	c := &checkpointer{path: path}
+	loaded := make(map[string][4]float64)
+	require.NoError(t, c.load(loaded))
+	require.Equal(t, 26, c.offset)
+	require.Equal(t, stats, loaded)
+
+	require.NoError(t, c.remove())
+	require.NoError(t, c.load(loaded), "a missing checkpoint starts from the beginning")
+	require.NoError(t, c.remove(), "removing twice is fine")
+
+	require.NoError(t, os.WriteFile(path, []byte("Hamburg;8;20;2;12\n"), 0o600))
+	require.ErrorContains(t, c.load(loaded), "invalid checkpoint header")
+}
+
+// TestParseCheckpointInterval tests parsing byte and line intervals.
+func TestParseCheckpointInterval(t *testing.T) {
+	every, everyLines, err := parseCheckpointInterval("4096")
+	require.NoError(t, err)
+	require.Equal(t, 4096, every)
+	require.Zero(t, everyLines)
+
+	every, everyLines, err = parseCheckpointInterval("500lines")
+	require.NoError(t, err)
+	require.Zero(t, every)
+	require.Equal(t, int64(500), everyLines)
+
+	for _, value := range []string{"", "0", "-1", "lines", "0lines", "1MiB"} {
+		_, _, err = parseCheckpointInterval(value)
+		require.ErrorContains(t, err, "invalid -checkpoint-interval", value)
+	}
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestProcessFile_Resume tests that a run interrupted halfway and resumed from its checkpoint
+// produces the same stats as an uninterrupted run.
+func TestProcessFile_Resume(t *testing.T) {
+	var data strings.Builder
+	for i := range 200 {
+		data.WriteString([]string{"Hamburg;12.0\n", "Berlin;-3.5\n", "Oslo;7.25\n"}[i%3])
+	}
+	clean := data.String()
+	half := strings.Index(clean[len(clean)/2:], "\n") + len(clean)/2 + 1
+	broken := clean[:half] + "garbage\n" + clean[half:]
+
+	file := createTestFile(t, broken)
+	defer cleanupTestFile(t, file)
+	checkpoint := filepath.Join(t.TempDir(), "run.checkpoint")
+
+	interrupted := newProcessor(options{})
+	interrupted.checkpoint = &checkpointer{path: checkpoint, every: 64}
+	require.ErrorContains(t, interrupted.processFile(file.Name()), "could not parse line: garbage")
+	require.LessOrEqual(t, half-64, interrupted.checkpoint.offset, "the last checkpoint is close to the failure")
+
+	require.NoError(t, os.WriteFile(file.Name(), []byte(clean), 0o600))
+	resumed := newProcessor(options{})
+	resumed.checkpoint = &checkpointer{path: checkpoint, every: 64}
+	require.NoError(t, resumed.checkpoint.load(resumed.stats))
+	require.NoError(t, resumed.processFile(file.Name()))
+
+	uninterrupted := newProcessor(options{})
+	require.NoError(t, uninterrupted.processFile(file.Name()))
+	require.Equal(t, uninterrupted.stats, resumed.stats)
+}
+
+// TestProcessFile_CheckpointInterval tests that a small interval, in bytes or in lines,
+// saves many checkpoints during a run, the last of them close to the end of the file.
+func TestProcessFile_CheckpointInterval(t *testing.T) {
+	const line = "Hamburg;12.0\n"
+	file := createTestFile(t, strings.Repeat(line, 100))
+	defer cleanupTestFile(t, file)
+	size := 100 * len(line)
+
+	for _, tc := range []struct {
+		name       string
+		every      int
+		everyLines int64
+		saved      int
+	}{
+		{"bytes", 10 * len(line), 0, 10},
+		{"lines", 0, 25, 4},
+	} {
+		checkpoint := filepath.Join(t.TempDir(), "run.checkpoint")
+		p := newProcessor(options{})
+		p.checkpoint = &checkpointer{path: checkpoint, every: tc.every, everyLines: tc.everyLines}
+		require.NoError(t, p.processFile(file.Name()), tc.name)
+		require.Equal(t, tc.saved, p.checkpoint.saved, tc.name)
+
+		last := &checkpointer{path: checkpoint}
+		stats := make(map[string][4]float64)
+		require.NoError(t, last.load(stats), tc.name)
+		require.Equal(t, size, last.offset, tc.name)
+		require.Equal(t, [4]float64{12.0, 1200.0, 100.0, 12.0}, stats["Hamburg"], tc.name)
+	}
+}
+
+// TestRun_Resume tests -checkpoint and -resume end to end: the checkpoint of a failed run
+// is resumed, and removed once the run completes.
+func TestRun_Resume(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\n")
+	defer cleanupTestFile(t, file)
+	checkpoint := filepath.Join(t.TempDir(), "run.checkpoint")
+	require.NoError(t, (&checkpointer{path: checkpoint}).save(13, map[string][4]float64{"Hamburg": {8.0, 8.0, 1.0, 8.0}}))
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-checkpoint", checkpoint, "-resume", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/8.0/8.0}\n\n", stdout.String(), "the first line is covered by the checkpoint")
+	require.NoFileExists(t, checkpoint)
+
+	err := run([]string{"-resume", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.EqualError(t, err, "-resume requires -checkpoint")
+
+	err = run([]string{"-checkpoint", checkpoint, "-checkpoint-interval", "0lines", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "invalid -checkpoint-interval")
+}
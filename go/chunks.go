@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// chunkScanSize is how many bytes are read at a time when looking for the newline that
+// ends a chunk.
+const chunkScanSize = 64 << 10
+
+// computeChunkOffsets splits the file at path into at most k chunks of roughly equal size
+// and returns their boundaries: chunk i spans [offsets[i], offsets[i+1]). The first offset
+// is 0, the last is the file size, and every other one is just after a newline, so the
+// chunks tile the file without splitting a line. Fewer than k chunks are returned when the
+// file has too few lines to fill them.
+func computeChunkOffsets(path string, k int) ([]int64, error) {
+	if k < 1 {
+		return nil, fmt.Errorf("chunk count must be positive, got %d", k)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("could not get file info: %w", err)
+	}
+	size := info.Size()
+
+	offsets := []int64{0}
+	for i := 1; i < k; i++ {
+		target := size * int64(i) / int64(k)
+		if previous := offsets[len(offsets)-1]; target <= previous {
+			continue // the previous chunk's line already extends past this target
+		}
+
+		// Start at target-1 so a target just after a newline is kept as is.
+		offset, err := nextLineStart(file, target-1)
+		if err != nil {
+			return nil, err
+		}
+		if offset >= size {
+			break
+		}
+		if offset > offsets[len(offsets)-1] {
+			offsets = append(offsets, offset)
+		}
+	}
+	if size > 0 {
+		offsets = append(offsets, size)
+	}
+
+	return offsets, nil
+}
+
+// nextLineStart returns the offset just after the first newline at or after from, or the
+// file size if there is none.
+func nextLineStart(file *os.File, from int64) (int64, error) {
+	buf := make([]byte, chunkScanSize)
+	for {
+		n, err := file.ReadAt(buf, from)
+		if i := bytes.IndexByte(buf[:n], '\n'); i != -1 {
+			return from + int64(i) + 1, nil
+		}
+		from += int64(n)
+		if errors.Is(err, io.EOF) {
+			return from, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("could not read file: %w", err)
+		}
+	}
+}
+
+// writeChunkOffsets prints the chunk boundaries of the file at path, one per line.
+func writeChunkOffsets(w io.Writer, path string, k int) error {
+	offsets, err := computeChunkOffsets(path, k)
+	if err != nil {
+		return &inputError{path: path, err: err}
+	}
+	for _, offset := range offsets {
+		if _, err = fmt.Fprintln(w, offset); err != nil {
+			return fmt.Errorf("could not write output: %w", err)
+		}
+	}
+	return nil
+}
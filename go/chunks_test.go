@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestComputeChunkOffsets tests that the offsets align to newlines and tile the whole file.
+func TestComputeChunkOffsets(t *testing.T) {
+	data := "Hamburg;12.0\nBerlin;20.0\nOslo;-5.0\nA very long station name;1.0\nRome;25.0\nX;0.0\n"
+	file := createTestFile(t, data)
+	defer cleanupTestFile(t, file)
+
+	for k := 1; k <= 10; k++ {
+		offsets, err := computeChunkOffsets(file.Name(), k)
+		require.NoError(t, err)
+
+		require.LessOrEqual(t, len(offsets)-1, k, "at most k chunks")
+		require.Equal(t, int64(0), offsets[0])
+		require.Equal(t, int64(len(data)), offsets[len(offsets)-1])
+		var chunks strings.Builder
+		for i := 1; i < len(offsets); i++ {
+			require.Greater(t, offsets[i], offsets[i-1], "chunks are non-empty")
+			require.Equal(t, byte('\n'), data[offsets[i]-1], "offset %d follows a newline", offsets[i])
+			chunks.WriteString(data[offsets[i-1]:offsets[i]])
+		}
+		require.Equal(t, data, chunks.String())
+	}
+
+	offsets, err := computeChunkOffsets(file.Name(), 1)
+	require.NoError(t, err)
+	require.Equal(t, []int64{0, int64(len(data))}, offsets)
+}
+
+// TestComputeChunkOffsets_Edges tests an empty file, a file without a final newline and an invalid k.
+func TestComputeChunkOffsets_Edges(t *testing.T) {
+	empty := createTestFile(t, "")
+	defer cleanupTestFile(t, empty)
+	offsets, err := computeChunkOffsets(empty.Name(), 4)
+	require.NoError(t, err)
+	require.Equal(t, []int64{0}, offsets)
+
+	unterminated := createTestFile(t, "Hamburg;12.0\nBerlin;20.0")
+	defer cleanupTestFile(t, unterminated)
+	offsets, err = computeChunkOffsets(unterminated.Name(), 2)
+	require.NoError(t, err)
+	require.Equal(t, []int64{0, 13, 24}, offsets)
+
+	_, err = computeChunkOffsets(unterminated.Name(), 0)
+	require.EqualError(t, err, "chunk count must be positive, got 0")
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_Offsets tests that -offsets prints the boundaries instead of the stats.
+func TestRun_Offsets(t *testing.T) {
+	file := createTestFile(t, strings.Repeat("Hamburg;12.0\n", 100))
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-offsets", "4", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "0\n325\n650\n975\n1300\n", stdout.String())
+
+	info, err := os.Stat(file.Name())
+	require.NoError(t, err)
+	require.Equal(t, int64(1300), info.Size())
+}
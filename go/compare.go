@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// compareCommand is the subcommand that reconciles the stations of two files.
+const compareCommand = "compare"
+
+// runCompare implements `compare a.txt b.txt`: it aggregates both files and prints the
+// stations only one of them has, then the common stations whose min, max or count differ.
+func runCompare(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet(compareCommand, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return errors.New("usage: compare a.txt b.txt")
+	}
+
+	var results [2]Result
+	for i, path := range fs.Args() {
+		p := newProcessor(options{})
+		if err := p.processFile(path); err != nil {
+			return &inputError{path: path, err: err}
+		}
+		results[i] = FromMap(p.stats)
+	}
+
+	_, err := io.WriteString(stdout, formatComparison(fs.Arg(0), fs.Arg(1), compareResults(results[0], results[1])))
+	return err
+}
+
+// comparison is the difference between two results.
+type comparison struct {
+	onlyA, onlyB []string      // stations of one result only, sorted
+	changed      []stationDiff // common stations with a different min, max or count, sorted
+}
+
+// stationDiff describes how a station common to both results differs.
+type stationDiff struct {
+	station string
+	a, b    Stats
+}
+
+// compareResults computes the symmetric difference of the station sets of a and b and the
+// common stations whose min, max or count differ. Means follow from the others and the
+// sum, so they aren't compared separately.
+func compareResults(a, b Result) comparison {
+	var c comparison
+	for _, station := range slices.Sorted(maps.Keys(a)) {
+		other, common := b[station]
+		switch s := a[station]; {
+		case !common:
+			c.onlyA = append(c.onlyA, station)
+		case s.Min != other.Min || s.Max != other.Max || s.Count != other.Count:
+			c.changed = append(c.changed, stationDiff{station: station, a: s, b: other})
+		}
+	}
+	for _, station := range slices.Sorted(maps.Keys(b)) {
+		if _, common := a[station]; !common {
+			c.onlyB = append(c.onlyB, station)
+		}
+	}
+	return c
+}
+
+// formatComparison formats c with one line per station, naming the files as nameA and
+// nameB, e.g.
+//
+//	only in a.txt: Oslo
+//	only in b.txt: Tokyo
+//	differs: Berlin min -3.0 != -5.0, count 3 != 2
+func formatComparison(nameA, nameB string, c comparison) string {
+	if len(c.onlyA) == 0 && len(c.onlyB) == 0 && len(c.changed) == 0 {
+		return "no differences\n"
+	}
+
+	var out strings.Builder
+	for _, station := range c.onlyA {
+		fmt.Fprintf(&out, "only in %s: %s\n", nameA, station)
+	}
+	for _, station := range c.onlyB {
+		fmt.Fprintf(&out, "only in %s: %s\n", nameB, station)
+	}
+	for _, d := range c.changed {
+		var fields []string
+		if d.a.Min != d.b.Min {
+			fields = append(fields, fmt.Sprintf("min %.1f != %.1f", d.a.Min, d.b.Min))
+		}
+		if d.a.Max != d.b.Max {
+			fields = append(fields, fmt.Sprintf("max %.1f != %.1f", d.a.Max, d.b.Max))
+		}
+		if d.a.Count != d.b.Count {
+			fields = append(fields, fmt.Sprintf("count %d != %d", d.a.Count, d.b.Count))
+		}
+		fmt.Fprintf(&out, "differs: %s %s\n", d.station, strings.Join(fields, ", "))
+	}
+	return out.String()
+}
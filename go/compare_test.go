@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestCompareResults tests the symmetric difference and the value differences of two results.
+func TestCompareResults(t *testing.T) {
+	a := Result{
+		"Berlin":  {Min: -3.0, Sum: 30.0, Count: 3, Max: 25.0},
+		"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+		"Oslo":    {Min: 1.0, Sum: 1.0, Count: 1, Max: 1.0},
+	}
+	b := Result{
+		"Berlin":  {Min: -5.0, Sum: 30.0, Count: 2, Max: 25.0},
+		"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+		"Tokyo":   {Min: 30.0, Sum: 30.0, Count: 1, Max: 30.0},
+		"Abha":    {Min: 20.0, Sum: 20.0, Count: 1, Max: 20.0},
+	}
+
+	c := compareResults(a, b)
+	require.Equal(t, []string{"Oslo"}, c.onlyA)
+	require.Equal(t, []string{"Abha", "Tokyo"}, c.onlyB)
+	require.Equal(t, []stationDiff{{station: "Berlin", a: a["Berlin"], b: b["Berlin"]}}, c.changed)
+
+	require.Equal(t, comparison{}, compareResults(a, a))
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_Compare tests the compare subcommand on files differing in membership and values.
+func TestRun_Compare(t *testing.T) {
+	a := createTestFile(t, "Berlin;-3.0\nBerlin;25.0\nHamburg;12.0\nOslo;1.0\n")
+	defer cleanupTestFile(t, a)
+	b := createTestFile(t, "Berlin;-5.0\nBerlin;25.0\nBerlin;10.0\nHamburg;12.0\nTokyo;30.0\n")
+	defer cleanupTestFile(t, b)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{compareCommand, a.Name(), b.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "only in "+a.Name()+": Oslo\n"+
+		"only in "+b.Name()+": Tokyo\n"+
+		"differs: Berlin min -3.0 != -5.0, count 2 != 3\n", stdout.String())
+
+	stdout.Reset()
+	require.NoError(t, run([]string{compareCommand, a.Name(), a.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "no differences\n", stdout.String())
+
+	err := run([]string{compareCommand, a.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.EqualError(t, err, "usage: compare a.txt b.txt")
+}
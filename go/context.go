@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// errorContextLines is how many lines are shown before and after a failing line.
+const errorContextLines = 2
+
+// reportErrorContext writes the line starting at offset start in data, with its surrounding
+// lines, to p.errorContext if it is set. Called at most once, as processing stops at the error.
+func (p *processor) reportErrorContext(data []byte, start int) {
+	if p.errorContext != nil {
+		writeErrorContext(p.errorContext, data, start, errorContextLines)
+	}
+}
+
+// writeErrorContext re-scans data around the line starting at offset start and writes it to
+// w with up to context lines on each side, each prefixed with its 1-based line number and
+// the failing one marked with '>':
+//
+//	   3 | Berlin;20.0
+//	>  4 | garbage
+//	   5 | Oslo;-5.0
+func writeErrorContext(w io.Writer, data []byte, start, context int) {
+	lineNo := bytes.Count(data[:start], []byte{'\n'}) + 1
+
+	// Walk back context lines from the failing one.
+	from, before := start, 0
+	for ; before < context && from > 0; before++ {
+		from = bytes.LastIndexByte(data[:from-1], '\n') + 1
+	}
+
+	// Walk forward to the end of the line context lines after the failing one.
+	to := start
+	for i := 0; i <= context && to < len(data); i++ {
+		if i > 0 {
+			to++ // skip the newline ending the previous line
+		}
+		if next := bytes.IndexByte(data[to:], '\n'); next == -1 {
+			to = len(data)
+		} else {
+			to += next
+		}
+	}
+
+	lines := bytes.Split(data[from:to], []byte{'\n'})
+	width := len(fmt.Sprint(lineNo - before + len(lines) - 1))
+	for i, line := range lines {
+		n := lineNo - before + i
+		marker := " "
+		if n == lineNo {
+			marker = ">"
+		}
+		_, _ = fmt.Fprintf(w, "%s %*d | %s\n", marker, width, n, bytes.TrimSuffix(line, []byte{'\r'}))
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestWriteErrorContext tests the context window in the middle of the data and at both ends.
+func TestWriteErrorContext(t *testing.T) {
+	data := []byte("l1\nl2\nl3\nl4\nl5\nl6\nl7\nl8\nl9\nl10\nl11")
+	lineStart := func(n int) int { return bytes.Index(data, fmt.Appendf(nil, "l%d\n", n)) }
+
+	tests := []struct {
+		line     int
+		expected string
+	}{
+		{5, "  3 | l3\n  4 | l4\n> 5 | l5\n  6 | l6\n  7 | l7\n"},
+		{1, "> 1 | l1\n  2 | l2\n  3 | l3\n"},
+		{2, "  1 | l1\n> 2 | l2\n  3 | l3\n  4 | l4\n"},
+		{11, "   9 | l9\n  10 | l10\n> 11 | l11\n"},
+		{9, "   7 | l7\n   8 | l8\n>  9 | l9\n  10 | l10\n  11 | l11\n"},
+	}
+	for _, tc := range tests {
+		var buf bytes.Buffer
+		start := len(data) - len("l11") // the last line has no newline
+		if tc.line < 11 {
+			start = lineStart(tc.line)
+		}
+		writeErrorContext(&buf, data, start, 2)
+		require.Equal(t, tc.expected, buf.String(), "line %d", tc.line)
+	}
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_ReparseOnError tests that a malformed line in the middle is printed with its context.
+func TestRun_ReparseOnError(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nOslo;-5.0\ngarbage\nRome;25.0\nParis;18.0\nLima;19.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stderr bytes.Buffer
+	err := run([]string{"-reparse-on-error", file.Name()}, nil, &bytes.Buffer{}, &stderr)
+	require.EqualError(t, err, "could not parse line: garbage")
+	require.Equal(t, ""+
+		"  2 | Berlin;20.0\n"+
+		"  3 | Oslo;-5.0\n"+
+		"> 4 | garbage\n"+
+		"  5 | Rome;25.0\n"+
+		"  6 | Paris;18.0\n", stderr.String())
+
+	stderr.Reset()
+	require.Error(t, run([]string{file.Name()}, nil, &bytes.Buffer{}, &stderr))
+	require.Empty(t, stderr.String(), "no context without -reparse-on-error")
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// isDir reports whether path names an existing directory.
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// listMeasurementFiles returns the *.txt and gzip-compressed *.txt.gz files directly inside
+// dir, sorted by name.
+func listMeasurementFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read directory: %w", err)
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && isMeasurementFile(name) {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// isMeasurementFile reports whether name has a measurement file extension, *.txt or *.txt.gz.
+func isMeasurementFile(name string) bool {
+	return filepath.Ext(name) == ".txt" || strings.HasSuffix(name, gzipExt)
+}
+
+// walkMeasurementFiles returns the *.txt and *.txt.gz files anywhere under root, sorted by
+// path, following symlinks to files. An unreadable subdirectory or a broken symlink is
+// passed to skip, which returns the error to abort the walk or nil to leave it out.
+func walkMeasurementFiles(root string, skip func(path string, err error) error) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return fmt.Errorf("could not read directory: %w", err)
+			}
+			if skipErr := skip(path, err); skipErr != nil {
+				return skipErr
+			}
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() || !isMeasurementFile(entry.Name()) {
+			return nil
+		}
+
+		mode := entry.Type()
+		if mode&fs.ModeSymlink != 0 {
+			info, statErr := os.Stat(path)
+			if statErr != nil {
+				return skip(path, statErr)
+			}
+			mode = info.Mode()
+		}
+		if mode.IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	return paths, nil
+}
+
+// processDir aggregates every *.txt file directly inside dir into p.
+//
+// At most workers files are processed concurrently (runtime.NumCPU() if workers < 1),
+// each by its own processor running the regular single-file engine, and the partial
+// results are merged into p as they complete. Every file's station and line counts are
+// logged before its merge.
+//
+// With opts.recursive, the files of subdirectories are included too. Files and
+// directories that can't be read are then reported to p.warnings and skipped, unless
+// opts.strict is set.
+func (p *processor) processDir(dir string, workers int) error {
+	if p.groups != nil {
+		return errors.New("-sorted-input can't be used with a directory")
+	}
+	if p.tee != nil {
+		return errors.New("-tee can't be used with a directory")
+	}
+
+	skip := func(path string, err error) error {
+		if p.opts.strict {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if p.warnings != nil {
+			fmt.Fprintf(p.warnings, "skipping %s: %v\n", path, err)
+		}
+		return nil
+	}
+
+	var paths []string
+	var err error
+	if p.opts.recursive {
+		paths, err = walkMeasurementFiles(dir, skip)
+	} else {
+		paths, err = listMeasurementFiles(dir)
+	}
+	if err != nil {
+		return err
+	}
+	p.logger.Debug("processing directory", "dir", dir, "files", len(paths))
+
+	var mu sync.Mutex // guards p while merging
+	return runBounded(workers, paths, func(path string) error {
+		opts := p.opts
+		opts.seed = deriveSeed(opts.seed, filepath.Base(path))
+		partial := newProcessor(opts)
+		partial.logger = p.logger
+		process := partial.processFile
+		if strings.HasSuffix(path, gzipExt) {
+			process = partial.processGzipFile // decompressed by this worker, concurrently with the others
+		}
+		if err := process(path); err != nil {
+			if p.opts.recursive && errors.Is(err, fs.ErrPermission) {
+				mu.Lock()
+				defer mu.Unlock()
+				return skip(path, err)
+			}
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		// Explains each file's contribution, so an empty or malformed input stands out.
+		p.logger.Info("merging file", "file", path, "stations", len(partial.stats), "lines", partial.lines)
+
+		mu.Lock()
+		defer mu.Unlock()
+		p.merge(partial)
+
+		return nil
+	})
+}
+
+// runBounded calls fn for every job with at most n calls in flight (runtime.NumCPU() if
+// n < 1). It waits for all calls to finish and returns the first error encountered;
+// jobs that haven't started yet are skipped once an error occurred.
+func runBounded(n int, jobs []string, fn func(job string) error) error {
+	if n < 1 {
+		n = runtime.NumCPU()
+	}
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+		failed   = make(chan struct{})
+		slots    = make(chan struct{}, n)
+	)
+	for _, job := range jobs {
+		select {
+		case slots <- struct{}{}:
+		case <-failed:
+		}
+		select {
+		case <-failed:
+			wg.Wait()
+			return firstErr
+		default:
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			if err := fn(job); err != nil {
+				once.Do(func() {
+					firstErr = err
+					close(failed)
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
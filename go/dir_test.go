@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestRunBounded_Limit tests that runBounded never exceeds its concurrency limit.
+func TestRunBounded_Limit(t *testing.T) {
+	jobs := make([]string, 20)
+	var inFlight, peak, calls atomic.Int32
+
+	err := runBounded(3, jobs, func(string) error {
+		calls.Add(1)
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			seen := peak.Load()
+			if current <= seen || peak.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, int32(20), calls.Load())
+	require.LessOrEqual(t, peak.Load(), int32(3))
+	require.Equal(t, int32(3), peak.Load(), "the pool should actually run jobs concurrently")
+}
+
+// TestRunBounded_Error tests that the first error is returned and later jobs are skipped.
+func TestRunBounded_Error(t *testing.T) {
+	errBoom := errors.New("boom")
+	var calls atomic.Int32
+
+	err := runBounded(1, make([]string, 10), func(string) error {
+		calls.Add(1)
+		return errBoom
+	})
+
+	require.ErrorIs(t, err, errBoom)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+// TestProcessor_Merge tests merging two processors' stats and histograms.
+func TestProcessor_Merge(t *testing.T) {
+	a := newProcessor(options{distinct: true})
+	require.NoError(t, a.processLine("Hamburg;12.0"))
+	require.NoError(t, a.processLine("Berlin;20.0"))
+	b := newProcessor(options{distinct: true})
+	require.NoError(t, b.processLine("Hamburg;8.0"))
+	require.NoError(t, b.processLine("Oslo;-5.0"))
+
+	a.merge(b)
+
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0, Oslo=-5.0/-5.0/-5.0}", formatOutput(a.stats))
+	require.Equal(t, 2, a.hists["Hamburg"].distinct())
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestProcessDir tests that every *.txt file of a directory is processed and merged.
+func TestProcessDir(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 8; i++ {
+		data := fmt.Sprintf("Hamburg;%d.0\nBerlin;-%d.5\n", i, i)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("part-%d.txt", i)), []byte(data), 0o600))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("not measurements"), 0o600))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "nested.txt"), 0o700))
+
+	p := newProcessor(options{})
+	require.NoError(t, p.processDir(dir, 3))
+
+	require.Equal(t, [4]float64{0.0, 28.0, 8.0, 7.0}, p.stats["Hamburg"])
+	require.Equal(t, [4]float64{-7.5, -32.0, 8.0, -0.5}, p.stats["Berlin"])
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-file-workers", "2", dir}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=-7.5/-4.0/-0.5, Hamburg=0.0/3.5/7.0}\n\n", stdout.String())
+}
+
+// TestRun_ExplainMerge tests that verbose directory runs log each file's contribution.
+func TestRun_ExplainMerge(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "a.txt")
+	second := filepath.Join(dir, "b.txt")
+	require.NoError(t, os.WriteFile(first, []byte("Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\n"), 0o600))
+	require.NoError(t, os.WriteFile(second, []byte(""), 0o600))
+
+	var stderr bytes.Buffer
+	require.NoError(t, run([]string{"-v", dir}, nil, &bytes.Buffer{}, &stderr))
+
+	require.Contains(t, stderr.String(), fmt.Sprintf(`msg="merging file" file=%s stations=2 lines=3`, first))
+	require.Contains(t, stderr.String(), fmt.Sprintf(`msg="merging file" file=%s stations=0 lines=0`, second))
+}
+
+// TestRun_GzipShards tests that a directory of gzipped shards is decompressed and merged,
+// alongside a plain file.
+func TestRun_GzipShards(t *testing.T) {
+	dir := t.TempDir()
+	writeGzip(t, filepath.Join(dir, "a.txt.gz"), "Hamburg;12.0\nBerlin;20.0\n")
+	writeGzip(t, filepath.Join(dir, "b.txt.gz"), "Hamburg;8.0\nBerlin;25.0")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "c.txt"), []byte("Oslo;-5.0\n"), 0o600))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"-v", "-file-workers", "2", dir}, nil, &stdout, &stderr))
+	require.Equal(t, "{Berlin=20.0/22.5/25.0, Hamburg=8.0/10.0/12.0, Oslo=-5.0/-5.0/-5.0}\n\n", stdout.String())
+
+	require.Equal(t, 2, strings.Count(stderr.String(), `msg="decompressed file"`))
+	for _, name := range []string{"a.txt.gz", "b.txt.gz"} {
+		require.Contains(t, stderr.String(), "path="+filepath.Join(dir, name))
+	}
+}
+
+// TestRun_Recursive tests that -recursive finds and merges the files of nested directories,
+// and skips a broken symlink with a warning unless -strict is set.
+func TestRun_Recursive(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2024", "01"), 0o700))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "2025"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "top.txt"), []byte("Hamburg;12.0\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2024", "01", "a.txt"), []byte("Hamburg;8.0\nBerlin;20.0\n"), 0o600))
+	writeGzip(t, filepath.Join(dir, "2025", "b.txt.gz"), "Berlin;25.0\nOslo;-5.0\n")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "2025", "notes.md"), []byte("not measurements"), 0o600))
+
+	paths, err := walkMeasurementFiles(dir, func(string, error) error { return nil })
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		filepath.Join(dir, "2024", "01", "a.txt"),
+		filepath.Join(dir, "2025", "b.txt.gz"),
+		filepath.Join(dir, "top.txt"),
+	}, paths)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{dir}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Hamburg=12.0/12.0/12.0}\n\n", stdout.String(), "only the top level without -recursive")
+
+	broken := filepath.Join(dir, "2025", "broken.txt")
+	require.NoError(t, os.Symlink(filepath.Join(dir, "missing.txt"), broken))
+
+	var stderr bytes.Buffer
+	stdout.Reset()
+	require.NoError(t, run([]string{"-recursive", dir}, nil, &stdout, &stderr))
+	require.Equal(t, "{Berlin=20.0/22.5/25.0, Hamburg=8.0/10.0/12.0, Oslo=-5.0/-5.0/-5.0}\n\n", stdout.String())
+	require.Contains(t, stderr.String(), "skipping "+broken)
+
+	err = run([]string{"-recursive", "-strict", dir}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, broken)
+}
+
+// TestProcessDir_Error tests that a malformed file fails the whole directory.
+func TestProcessDir_Error(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("Hamburg;1.0\n"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b.txt"), []byte("garbage\n"), 0o600))
+
+	err := newProcessor(options{}).processDir(dir, 2)
+	require.ErrorContains(t, err, "b.txt: could not parse line: garbage")
+}
+
+// -------------------------------------------- Test Helper Functions --------------------------------------------
+
+// writeGzip writes data gzip-compressed to path.
+func writeGzip(t *testing.T, path, data string) {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+}
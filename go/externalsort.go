@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"os"
+)
+
+// defaultSortRunSize is the default number of stations per sorted run of the external sort.
+const defaultSortRunSize = 100_000
+
+// writeSortedExternal writes the stats in the text format, sorted by name, to the file at
+// path using an external merge sort: the stations are written in sorted runs of at most
+// runSize to temporary files in tmpDir (the default temp directory if empty), which are
+// then k-way merged into the output. Only one run and one station per run are held in
+// memory at a time besides the stats themselves, so no sorted copy of all the stations is
+// built.
+func writeSortedExternal(path string, stats map[string][4]float64, runSize int, tmpDir string) (err error) {
+	runs, err := writeSortedRuns(stats, runSize, tmpDir)
+	defer func() {
+		for _, run := range runs {
+			_ = os.Remove(run)
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create output file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("could not close output file: %w", closeErr)
+		}
+	}()
+
+	w := bufio.NewWriter(file)
+	if err = mergeRuns(w, runs); err != nil {
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		return fmt.Errorf("could not write output file: %w", err)
+	}
+	return nil
+}
+
+// writeSortedRuns writes the stats to temporary files of at most runSize stations each,
+// every file sorted by name in the intermediate format, and returns their paths. The paths
+// already created are returned even on error, so the caller can remove them.
+func writeSortedRuns(stats map[string][4]float64, runSize int, tmpDir string) ([]string, error) {
+	var runs []string
+	run := make(Result, min(runSize, len(stats)))
+	flush := func() error {
+		file, err := os.CreateTemp(tmpDir, "letsgomeeeeeow-run-*.txt")
+		if err != nil {
+			return fmt.Errorf("could not create sorted run: %w", err)
+		}
+		runs = append(runs, file.Name())
+
+		w := bufio.NewWriter(file)
+		err = WriteIntermediate(w, run)
+		if err == nil {
+			err = w.Flush()
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return fmt.Errorf("could not write sorted run: %w", err)
+		}
+		clear(run)
+		return nil
+	}
+
+	for station, tup := range stats {
+		run[station] = Stats{Min: tup[0], Sum: tup[1], Count: int64(tup[2]), Max: tup[3]}
+		if len(run) == runSize {
+			if err := flush(); err != nil {
+				return runs, err
+			}
+		}
+	}
+	if len(run) > 0 {
+		if err := flush(); err != nil {
+			return runs, err
+		}
+	}
+	return runs, nil
+}
+
+// mergeRuns k-way merges the sorted runs into w as a `{station=min/mean/max, ...}` line.
+func mergeRuns(w io.Writer, runs []string) error {
+	h := make(runHeap, 0, len(runs))
+	for _, path := range runs {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("could not open sorted run: %w", err)
+		}
+		defer func() { _ = file.Close() }()
+
+		r := &runReader{scanner: bufio.NewScanner(file)}
+		if ok, err := r.next(); err != nil {
+			return err
+		} else if ok {
+			h = append(h, r)
+		}
+	}
+	heap.Init(&h)
+
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return fmt.Errorf("could not write output: %w", err)
+	}
+	for first := true; len(h) > 0; first = false {
+		r := h[0]
+		if !first {
+			if _, err := io.WriteString(w, ", "); err != nil {
+				return fmt.Errorf("could not write output: %w", err)
+			}
+		}
+		s := r.stats
+		if _, err := fmt.Fprintf(w, "%s=%.1f/%.1f/%.1f", r.station, s.Min, s.Mean(), s.Max); err != nil {
+			return fmt.Errorf("could not write output: %w", err)
+		}
+
+		ok, err := r.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	if _, err := io.WriteString(w, "}\n"); err != nil {
+		return fmt.Errorf("could not write output: %w", err)
+	}
+	return nil
+}
+
+// runReader reads the stations of a sorted run one at a time.
+type runReader struct {
+	scanner *bufio.Scanner
+	station string
+	stats   Stats
+}
+
+// next advances to the run's next station, returning false at the end of the run.
+func (r *runReader) next() (bool, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return false, fmt.Errorf("could not read sorted run: %w", err)
+		}
+		return false, nil
+	}
+	station, stats, err := parseIntermediateLine(r.scanner.Text())
+	if err != nil {
+		return false, fmt.Errorf("could not read sorted run: %w", err)
+	}
+	r.station, r.stats = station, stats
+	return true, nil
+}
+
+// runHeap is a min-heap of runs ordered by their current station.
+type runHeap []*runReader
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return h[i].station < h[j].station }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x any)        { *h = append(*h, x.(*runReader)) }
+
+func (h *runHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestWriteSortedExternal tests that stations spread over many small runs are merged back
+// into complete, sorted output matching the in-memory formatter.
+func TestWriteSortedExternal(t *testing.T) {
+	stats := make(map[string][4]float64)
+	for i := range 50 {
+		v := float64(i%7) - 3
+		stats[fmt.Sprintf("Station%02d", (i*17)%50)] = [4]float64{v, v * 3, 3, v + 1}
+	}
+	tmpDir := t.TempDir()
+
+	runs, err := writeSortedRuns(stats, 4, tmpDir)
+	require.NoError(t, err)
+	require.Len(t, runs, 13)
+
+	path := filepath.Join(t.TempDir(), "out.txt")
+	require.NoError(t, writeSortedExternal(path, stats, 4, tmpDir))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, formatOutput(stats)+"\n", string(got))
+
+	for _, run := range runs {
+		require.NoError(t, os.Remove(run))
+	}
+	left, err := os.ReadDir(tmpDir)
+	require.NoError(t, err)
+	require.Empty(t, left, "the sorted runs should be removed")
+}
+
+// TestWriteSortedExternal_Empty tests that no stations yield an empty result.
+func TestWriteSortedExternal_Empty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	require.NoError(t, writeSortedExternal(path, nil, 4, t.TempDir()))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "{}\n", string(got))
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_SortedOutputToFile tests that -sorted-output-to-file writes the result to the
+// file instead of stdout.
+func TestRun_SortedOutputToFile(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "measurements.txt")
+	require.NoError(t, os.WriteFile(input, []byte("Oslo;-5.0\nHamburg;12.0\nBerlin;20.0\nHamburg;8.0\n"), 0o600))
+	path := filepath.Join(t.TempDir(), "out.txt")
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-sorted-output-to-file", path, "-sort-run-size", "1", input}, nil, &stdout, &bytes.Buffer{}))
+	require.Empty(t, stdout.String())
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0, Oslo=-5.0/-5.0/-5.0}\n", string(got))
+
+	err = run([]string{"-sort-run-size", "0", input}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-sort-run-size must be at least 1")
+}
+
+// TestRun_SortedOutputToFileRejects tests that -sorted-output-to-file refuses the options it
+// would ignore.
+func TestRun_SortedOutputToFileRejects(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "measurements.txt")
+	require.NoError(t, os.WriteFile(input, []byte("Oslo;-5.0\nBerlin;20.0\n"), 0o600))
+	path := filepath.Join(t.TempDir(), "out.txt")
+
+	for _, args := range [][]string{
+		{"-format", "table"},
+		{"-format", "sqlite", "-o", filepath.Join(t.TempDir(), "out.db")},
+		{"-sort", "mean"},
+		{"-sort-desc"},
+		{"-min-only"},
+		{"-distinct"},
+		{"-percentiles", "50"},
+		{"-first-last"},
+		{"-cv"},
+	} {
+		err := run(append(append([]string{"-sorted-output-to-file", path}, args...), input), nil, &bytes.Buffer{}, &bytes.Buffer{})
+		require.ErrorContains(t, err, "-sorted-output-to-file can't be combined with", args)
+	}
+	require.NoFileExists(t, path)
+}
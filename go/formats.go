@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// FormatFunc writes a result to w in some output format.
+type FormatFunc func(w io.Writer, result Result) error
+
+var (
+	formatsMu sync.RWMutex
+	formats   = map[string]FormatFunc{
+		formatMsgpack: writeMsgpack,
+	}
+)
+
+// RegisterFormat makes an output format available to -format under name. The built-in
+// text, table and sqlite formats are handled by the command itself and can't be replaced.
+// It panics if name is empty, already registered or fn is nil, like database/sql.Register.
+func RegisterFormat(name string, fn FormatFunc) {
+	formatsMu.Lock()
+	defer formatsMu.Unlock()
+
+	if name == "" || fn == nil {
+		panic("RegisterFormat: empty name or nil format func")
+	}
+	if _, exists := formats[name]; exists || name == formatText || name == formatTable || name == formatSQLite {
+		panic(fmt.Sprintf("RegisterFormat: format %q is already registered", name))
+	}
+	formats[name] = fn
+}
+
+// lookupFormat returns the registered format called name.
+func lookupFormat(name string) (FormatFunc, bool) {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	fn, ok := formats[name]
+	return fn, ok
+}
+
+// formatNames returns the names of every -format value, sorted.
+func formatNames() []string {
+	formatsMu.RLock()
+	defer formatsMu.RUnlock()
+	return slices.Sorted(maps.Keys(formats))
+}
+
+// writeFormatFile writes the result with fn to the file at path.
+func writeFormatFile(path string, fn FormatFunc, result Result) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("could not create output file: %w", err)
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("could not close output file: %w", closeErr)
+		}
+	}()
+
+	buffered := bufio.NewWriter(file)
+	if err = fn(buffered, result); err != nil {
+		return err
+	}
+	if err = buffered.Flush(); err != nil {
+		return fmt.Errorf("could not write output file: %w", err)
+	}
+	return nil
+}
+
+// outputTarget is one -out pair: the result is written in format to the file at path.
+type outputTarget struct {
+	format string
+	path   string
+}
+
+// outputTargets collects the repeatable -out flag.
+type outputTargets []outputTarget
+
+// String implements flag.Value.
+func (o *outputTargets) String() string {
+	pairs := make([]string, len(*o))
+	for i, target := range *o {
+		pairs[i] = target.format + "=" + target.path
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value, parsing a format=path pair.
+func (o *outputTargets) Set(value string) error {
+	format, path, ok := strings.Cut(value, "=")
+	if !ok || format == "" || path == "" {
+		return fmt.Errorf("want format=path, got %q", value)
+	}
+	switch format {
+	case formatText, formatTable, formatSQLite:
+	default:
+		if _, ok = lookupFormat(format); !ok {
+			return fmt.Errorf("unknown output format %q", format)
+		}
+	}
+	*o = append(*o, outputTarget{format: format, path: path})
+	return nil
+}
+
+// writeOutputTargets writes the stats to every -out target in turn. The text and table
+// formats are rendered with out, without color.
+func writeOutputTargets(targets outputTargets, stats map[string][4]float64, out outputOptions) error {
+	out.color = false
+	result := FromMap(stats)
+	writeFile := writeFormatFile
+	if out.mmap {
+		writeFile = writeFormatFileMmap
+	}
+	for _, target := range targets {
+		var err error
+		switch target.format {
+		case formatSQLite:
+			err = writeSQLite(target.path, stats)
+		case formatText:
+			err = writeFile(target.path, func(w io.Writer, _ Result) error {
+				_, writeErr := io.WriteString(w, formatOutputWith(stats, out)+"\n")
+				return writeErr
+			}, result)
+		case formatTable:
+			err = writeFile(target.path, func(w io.Writer, _ Result) error {
+				_, writeErr := io.WriteString(w, formatTableOutput(stats, out)+"\n")
+				return writeErr
+			}, result)
+		default:
+			fn, _ := lookupFormat(target.format) // validated by Set
+			err = writeFile(target.path, fn, result)
+		}
+		if err != nil {
+			return fmt.Errorf("-out %s=%s: %w", target.format, target.path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestRegisterFormat_Invalid tests that built-in, duplicate and empty registrations panic.
+func TestRegisterFormat_Invalid(t *testing.T) {
+	noop := func(io.Writer, Result) error { return nil }
+	for _, name := range []string{formatText, formatTable, formatSQLite, formatMsgpack, ""} {
+		require.Panics(t, func() { RegisterFormat(name, noop) }, name)
+	}
+	require.Panics(t, func() { RegisterFormat("nil-func", nil) })
+}
+
+// TestOutputTargets_Set tests parsing -out pairs.
+func TestOutputTargets_Set(t *testing.T) {
+	var targets outputTargets
+	require.NoError(t, targets.Set("text=out.txt"))
+	require.NoError(t, targets.Set("msgpack=a=b.msgpack"))
+	require.Equal(t, outputTargets{{formatText, "out.txt"}, {formatMsgpack, "a=b.msgpack"}}, targets)
+	require.Equal(t, "text=out.txt,msgpack=a=b.msgpack", targets.String())
+
+	require.ErrorContains(t, targets.Set("out.txt"), "want format=path")
+	require.ErrorContains(t, targets.Set("text="), "want format=path")
+	require.ErrorContains(t, targets.Set("yaml=out.yaml"), `unknown output format "yaml"`)
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRunMain_CustomFormat tests a registered format driven through the entrypoint, to stdout and to -o.
+func TestRunMain_CustomFormat(t *testing.T) {
+	if _, registered := lookupFormat("test-counts"); !registered { // the registry outlives -count runs
+		RegisterFormat("test-counts", func(w io.Writer, result Result) error {
+			for _, station := range slices.Sorted(maps.Keys(result)) {
+				if _, err := fmt.Fprintf(w, "%s %d\n", station, result[station].Count); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	require.Contains(t, formatNames(), "test-counts")
+
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, runMain([]string{"-format", "test-counts", file.Name()}, nil, &stdout, &stderr))
+	require.Equal(t, "Berlin 1\nHamburg 2\n", stdout.String())
+	require.Empty(t, stderr.String())
+
+	path := filepath.Join(t.TempDir(), "counts.txt")
+	require.Equal(t, 0, runMain([]string{"-format", "test-counts", "-o", path, file.Name()}, nil, &bytes.Buffer{}, &stderr))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "Berlin 1\nHamburg 2\n", string(data))
+
+	stderr.Reset()
+	require.Equal(t, 1, runMain([]string{"-format", "unregistered", file.Name()}, nil, &bytes.Buffer{}, &stderr))
+	require.Contains(t, stderr.String(), `unknown output format \"unregistered\"`)
+}
+
+// TestRun_MultipleOutputs tests that repeated -out flags serialize one aggregation to every
+// requested file, alongside the regular output.
+func TestRun_MultipleOutputs(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\n")
+	defer cleanupTestFile(t, file)
+	dir := t.TempDir()
+	textPath, msgpackPath := filepath.Join(dir, "out.txt"), filepath.Join(dir, "out.msgpack")
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-out", "text=" + textPath, "-out", "msgpack=" + msgpackPath, file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n\n", stdout.String())
+
+	text, err := os.ReadFile(textPath)
+	require.NoError(t, err)
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n", string(text))
+
+	data, err := os.ReadFile(msgpackPath)
+	require.NoError(t, err)
+	var decoded map[string][4]float64
+	require.NoError(t, msgpack.Unmarshal(data, &decoded))
+	require.Equal(t, map[string][4]float64{
+		"Berlin":  {20.0, 20.0, 20.0, 1},
+		"Hamburg": {8.0, 10.0, 12.0, 2},
+	}, decoded, "both files hold the same stats")
+
+	err = run([]string{"-out", "csv=out.csv", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, `unknown output format "csv"`)
+}
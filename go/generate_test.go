@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// generatedStations is the number of stations in generated measurements, as in the 1BRC.
+const generatedStations = 413
+
+// benchRowsEnv overrides how many lines the benchmark measurements file has.
+const benchRowsEnv = "LETSGO_BENCH_ROWS"
+
+// defaultBenchRows is the size of the benchmark measurements file without benchRowsEnv.
+const defaultBenchRows = 5_000_000
+
+// benchMeasurementsPath is the measurements file generated once by TestMain for the
+// benchmarks, empty unless benchmarks were requested with -bench.
+var benchMeasurementsPath string
+
+// TestMain generates the benchmark measurements file when benchmarks run, so every
+// benchmark and each of its -count runs reuse the same input, and removes it afterwards.
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if bench := flag.Lookup("test.bench"); bench == nil || bench.Value.String() == "" {
+		os.Exit(m.Run())
+	}
+
+	rows := defaultBenchRows
+	if value := os.Getenv(benchRowsEnv); value != "" {
+		var err error
+		if rows, err = strconv.Atoi(value); err != nil || rows < 1 {
+			fmt.Fprintf(os.Stderr, "invalid %s: %q\n", benchRowsEnv, value)
+			os.Exit(2)
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "letsgomeeeeeow-bench-*")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	benchMeasurementsPath = filepath.Join(dir, "measurements.txt")
+	if err = writeMeasurements(benchMeasurementsPath, rows, 1); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		_ = os.RemoveAll(dir)
+		os.Exit(2)
+	}
+
+	code := m.Run()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestGenerateMeasurements tests that generated measurements parse cleanly, stay within
+// the station count and temperature range, and are skewed towards some stations.
+func TestGenerateMeasurements(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, generateMeasurements(&buf, 50_000, 7))
+
+	p := newProcessor(options{})
+	require.NoError(t, p.processReader(&buf))
+	require.Equal(t, int64(50_000), p.lines)
+	require.LessOrEqual(t, len(p.stats), generatedStations)
+
+	fewest, most := math.Inf(1), 0.0
+	for _, tup := range p.stats {
+		require.GreaterOrEqual(t, tup[0], minTemperature)
+		require.LessOrEqual(t, tup[3], maxTemperature)
+		fewest, most = min(fewest, tup[2]), max(most, tup[2])
+	}
+	require.Greater(t, most, 10*fewest, "the station distribution is skewed")
+
+	var first, second bytes.Buffer
+	require.NoError(t, generateMeasurements(&first, 1_000, 7))
+	require.NoError(t, generateMeasurements(&second, 1_000, 7))
+	require.Equal(t, first.String(), second.String(), "the same seed generates the same file")
+}
+
+// BenchmarkProcessFile_Generated benchmarks processFile on the measurements file that
+// TestMain generates, sized with LETSGO_BENCH_ROWS.
+func BenchmarkProcessFile_Generated(b *testing.B) {
+	info, err := os.Stat(benchMeasurementsPath)
+	require.NoError(b, err)
+
+	b.SetBytes(info.Size())
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := newProcessor(options{}).processFile(benchMeasurementsPath); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// -------------------------------------------- Test Helper Functions --------------------------------------------
+
+// writeMeasurements writes rows generated measurement lines to the file at path.
+func writeMeasurements(path string, rows int, seed uint64) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	return generateMeasurements(file, rows, seed)
+}
+
+// generateMeasurements writes rows 1BRC-like lines to w: generatedStations stations, each
+// with its own mean temperature, drawn with a skew so a few stations dominate, with
+// normally distributed readings around the mean.
+func generateMeasurements(w io.Writer, rows int, seed uint64) error {
+	rng := newRand(seed)
+	means := make([]float64, generatedStations)
+	for i := range means {
+		means[i] = rng.Float64()*60 - 20
+	}
+
+	bw := bufio.NewWriter(w)
+	for range rows {
+		u := rng.Float64()
+		station := int(u * u * u * generatedStations) // cubing skews the draw towards low indexes
+		temperature := means[station] + rng.NormFloat64()*10
+		temperature = min(max(temperature, minTemperature), maxTemperature)
+		if _, err := fmt.Fprintf(bw, "Station %03d;%.1f\n", station, temperature); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
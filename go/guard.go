@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// errMemoryFault is wrapped by the error recoverFault turns a fault of the scanned mapping into.
+var errMemoryFault = errors.New("memory fault reading the mapped file")
+
+// checkFileSize returns an error if the file's current size differs from the size that
+// was mapped. Another process truncating or extending the file while it is mapped would
+// otherwise go unnoticed, or silently give results for part of it.
+func checkFileSize(file *os.File, mapped int64) error {
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("could not get file info: %w", err)
+	}
+	if info.Size() != mapped {
+		return fmt.Errorf("file size changed while processing: mapped %d bytes, now %d", mapped, info.Size())
+	}
+	return nil
+}
+
+// recoverFault is deferred around a scan of a memory mapping, with debug.SetPanicOnFault
+// enabled, to turn the fault of reading a page the file no longer backs (SIGBUS after a
+// truncation) into an error. Other panics are propagated.
+func recoverFault(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if fault, ok := r.(interface{ Addr() uintptr }); ok {
+		*err = fmt.Errorf("%w at %#x, was it truncated while processing?", errMemoryFault, fault.Addr())
+		return
+	}
+	panic(r)
+}
+
+// processFileRetrying is processFile for -retry-on-sigbus: a scan aborted by a memory fault,
+// because the file was truncated under the mapping, is retried on a private copy of the file,
+// which nothing else can change. Each attempt aggregates into a fresh processor merged into p
+// on success, so the readings of the aborted scan aren't counted twice.
+func (p *processor) processFileRetrying(filePath string) error {
+	attempt := p.attempt()
+	err := attempt.processFile(filePath)
+	if errors.Is(err, errMemoryFault) {
+		p.logger.Warn("retrying on a copy of the file", "path", filePath, "error", err)
+		attempt = p.attempt()
+		err = attempt.processFileCopy(filePath)
+	}
+	if err != nil {
+		return err
+	}
+	p.merge(attempt)
+	return nil
+}
+
+// attempt returns an empty processor sharing p's options and hooks, for one scan of
+// processFileRetrying.
+func (p *processor) attempt() *processor {
+	attempt := newProcessor(p.opts)
+	attempt.logger = p.logger
+	attempt.warnings = p.warnings
+	attempt.errorContext = p.errorContext
+	attempt.progress = p.progress
+	attempt.mapper = p.mapper
+	attempt.dropWindow = p.dropWindow
+	attempt.sizeHint = p.sizeHint
+	return attempt
+}
+
+// processFileCopy copies the file at filePath to a temporary file, processes the copy and
+// removes it.
+func (p *processor) processFileCopy(filePath string) error {
+	src, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+	defer func() { _ = src.Close() }()
+
+	dst, err := os.CreateTemp("", "letsgomeeeeeow-copy-*")
+	if err != nil {
+		return fmt.Errorf("could not create copy of the file: %w", err)
+	}
+	defer func() { _ = os.Remove(dst.Name()) }()
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		return fmt.Errorf("could not copy the file: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("could not close the copy of the file: %w", err)
+	}
+	p.logger.Debug("copied file", "path", filePath, "copy", dst.Name())
+
+	return p.processFile(dst.Name())
+}
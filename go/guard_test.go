@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestCheckFileSize tests the guard against a stable and a changed size.
+func TestCheckFileSize(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\n")
+	defer cleanupTestFile(t, file)
+
+	require.NoError(t, checkFileSize(file, 13))
+	require.EqualError(t, checkFileSize(file, 20), "file size changed while processing: mapped 20 bytes, now 13")
+}
+
+// TestRecoverFault tests that only fault panics are converted to errors.
+func TestRecoverFault(t *testing.T) {
+	faulting := func() (err error) {
+		defer recoverFault(&err)
+		panic(fakeFault{})
+	}
+	require.ErrorContains(t, faulting(), "memory fault reading the mapped file at 0x2a")
+
+	require.PanicsWithValue(t, "unrelated", func() {
+		var err error
+		defer recoverFault(&err)
+		panic("unrelated")
+	})
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestProcessFile_StableSize tests that a file whose size doesn't change is processed normally.
+func TestProcessFile_StableSize(t *testing.T) {
+	file := createTestFile(t, strings.Repeat("Hamburg;12.0\n", 1000))
+	defer cleanupTestFile(t, file)
+
+	p := newProcessor(options{})
+	require.NoError(t, p.processFile(file.Name()))
+	require.Equal(t, [4]float64{12.0, 12000.0, 1000.0, 12.0}, p.stats["Hamburg"])
+}
+
+// TestProcessFile_Truncated is a best-effort test truncating the file mid-scan: reading the
+// pages it no longer backs faults, and the scan must fail with an error rather than crash.
+func TestProcessFile_Truncated(t *testing.T) {
+	line := "Hamburg;12.0\n"
+	file := createTestFile(t, strings.Repeat(line, 3*progressStep/len(line)))
+	defer cleanupTestFile(t, file)
+
+	p := newProcessor(options{})
+	p.progress = func(done, total int64) {
+		if done < total {
+			require.NoError(t, os.Truncate(file.Name(), 0))
+		}
+	}
+	err := p.processFile(file.Name())
+	require.Error(t, err)
+	require.Regexp(t, "memory fault|file size changed", err.Error())
+}
+
+// TestProcessFile_MapFallback tests that a file which can't be memory-mapped is read into
+// memory instead, with the same stats.
+func TestProcessFile_MapFallback(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\r\nBerlin;20.0\nHamburg;8.0")
+	defer cleanupTestFile(t, file)
+
+	p := newProcessor(options{})
+	p.mapper = func(*os.File) ([]byte, error) { return nil, errors.New("mmap not supported") }
+	require.NoError(t, p.processFile(file.Name()))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}", formatOutput(p.stats))
+}
+
+// TestProcessFile_Proc tests reading a /proc file, which reports a size of zero.
+func TestProcessFile_Proc(t *testing.T) {
+	const path = "/proc/self/environ"
+	if _, err := os.Stat(path); err != nil {
+		t.Skip("no /proc filesystem")
+	}
+
+	p := newProcessor(options{ignoreErrors: true})
+	require.NoError(t, p.processFile(path))
+	require.Positive(t, p.lines, "the content is read despite the zero size")
+}
+
+// TestProcessFile_RetryOnFault is a best-effort test of -retry-on-sigbus: the injected mapper
+// first returns the mapping of a same-sized decoy file truncated behind it, so the scan faults
+// like a truncation of the input would, and the retry on the copy must give the file's stats.
+func TestProcessFile_RetryOnFault(t *testing.T) {
+	file := createTestFile(t, strings.Repeat("Hamburg;12.0\nBerlin;-3.5\n", 1000))
+	defer cleanupTestFile(t, file)
+	decoy := filepath.Join(t.TempDir(), "decoy.txt")
+
+	faulty := func(f *os.File) ([]byte, error) {
+		info, err := f.Stat()
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(decoy, bytes.Repeat([]byte("x"), int(info.Size())), 0o600))
+		file, err := os.Open(decoy)
+		require.NoError(t, err)
+		defer file.Close()
+		data, err := mmapFile(file)
+		require.NoError(t, err)
+		return data, os.Truncate(decoy, 0)
+	}
+
+	p := newProcessor(options{})
+	p.mapper = faulty
+	require.ErrorIs(t, p.processFile(file.Name()), errMemoryFault, "the injected mapping faults")
+
+	var mapped []string
+	p = newProcessor(options{retryOnFault: true})
+	p.mapper = func(f *os.File) ([]byte, error) {
+		mapped = append(mapped, f.Name())
+		if len(mapped) == 1 {
+			return faulty(f)
+		}
+		return mmapFile(f)
+	}
+	require.NoError(t, p.processFileRetrying(file.Name()))
+	require.Len(t, mapped, 2)
+	require.NotEqual(t, file.Name(), mapped[1], "the retry maps a copy")
+	require.NoFileExists(t, mapped[1], "the copy is removed")
+	require.Equal(t, "{Berlin=-3.5/-3.5/-3.5, Hamburg=12.0/12.0/12.0}", formatOutput(p.stats))
+	require.Equal(t, [4]float64{12.0, 12000.0, 1000.0, 12.0}, p.stats["Hamburg"], "the aborted scan isn't counted")
+	require.Equal(t, int64(2000), p.lines)
+
+	err := run([]string{"-retry-on-sigbus", stdinPath}, strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{})
+	require.EqualError(t, err, "-retry-on-sigbus requires a single input file")
+}
+
+// -------------------------------------------- Test Helper Functions --------------------------------------------
+
+// fakeFault mimics the runtime error raised for a memory fault with debug.SetPanicOnFault.
+type fakeFault struct{}
+
+func (fakeFault) Error() string { return "fault" }
+func (fakeFault) Addr() uintptr { return 42 }
+func (fakeFault) RuntimeError() {}
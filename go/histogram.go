@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The histogram covers the 1BRC temperature range, -99.9 to 99.9, at one decimal of precision.
+const (
+	histogramMinTenths = -999
+	histogramMaxTenths = 999
+	histogramBuckets   = histogramMaxTenths - histogramMinTenths + 1
+)
+
+// histogram counts a station's readings per tenth of a degree.
+//
+// It holds the full distribution rather than running aggregates, so anything that
+// depends on individual values (distinct values, mode, percentiles) can be derived
+// from it after the scan.
+type histogram [histogramBuckets]int64
+
+// add records a single reading. Readings outside the covered range are counted in the
+// nearest edge bucket.
+func (h *histogram) add(temperature float64) {
+	tenths := int(math.Round(temperature * 10))
+	tenths = max(histogramMinTenths, min(histogramMaxTenths, tenths))
+	h[tenths-histogramMinTenths]++
+}
+
+// merge adds the counts of other into h.
+func (h *histogram) merge(other *histogram) {
+	for i, count := range other {
+		h[i] += count
+	}
+}
+
+// distinct returns the number of distinct temperatures recorded, i.e. nonzero buckets.
+func (h *histogram) distinct() int {
+	n := 0
+	for _, count := range h {
+		if count != 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// mode returns the most frequently recorded temperature, the lowest one on a tie.
+func (h *histogram) mode() float64 {
+	best := 0
+	for i, count := range h {
+		if count > h[best] {
+			best = i
+		}
+	}
+	return float64(best+histogramMinTenths) / 10
+}
+
+// percentile returns the nearest-rank q-th percentile (0 < q <= 100) of the recorded
+// temperatures, or NaN if there are none.
+func (h *histogram) percentile(q float64) float64 {
+	var total int64
+	for _, count := range h {
+		total += count
+	}
+	if total == 0 {
+		return math.NaN()
+	}
+	rank := max(int64(math.Ceil(q/100*float64(total))), 1)
+	var seen int64
+	for i, count := range h {
+		if seen += count; seen >= rank {
+			return float64(i+histogramMinTenths) / 10
+		}
+	}
+	return histogramMaxTenths / 10.0
+}
+
+// weightedHistogram is a histogram accumulating the weight of the readings per tenth of a
+// degree instead of their number, for -weighted percentiles.
+type weightedHistogram [histogramBuckets]float64
+
+// add records a reading with the given weight, like histogram.add.
+func (h *weightedHistogram) add(temperature, weight float64) {
+	tenths := int(math.Round(temperature * 10))
+	tenths = max(histogramMinTenths, min(histogramMaxTenths, tenths))
+	h[tenths-histogramMinTenths] += weight
+}
+
+// merge adds the weights of other into h.
+func (h *weightedHistogram) merge(other *weightedHistogram) {
+	for i, weight := range other {
+		h[i] += weight
+	}
+}
+
+// percentile returns the lowest temperature at which the cumulative weight reaches q
+// percent (0 < q <= 100) of the total, or NaN if the total weight is zero.
+func (h *weightedHistogram) percentile(q float64) float64 {
+	total := 0.0
+	for _, weight := range h {
+		total += weight
+	}
+	if total == 0 {
+		return math.NaN()
+	}
+	target, seen := q/100*total, 0.0
+	for i, weight := range h {
+		if seen += weight; weight > 0 && seen >= target {
+			return float64(i+histogramMinTenths) / 10
+		}
+	}
+	for i := len(h) - 1; ; i-- { // float rounding left the target just out of reach
+		if h[i] > 0 {
+			return float64(i+histogramMinTenths) / 10
+		}
+	}
+}
+
+// parsePercentiles parses the comma-separated -percentiles list, e.g. "50,90,99.9".
+func parsePercentiles(value string) ([]float64, error) {
+	var percentiles []float64
+	for field := range strings.SplitSeq(value, ",") {
+		q, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || !(q > 0 && q <= 100) {
+			return nil, fmt.Errorf("invalid percentile %q: want a number in (0, 100]", field)
+		}
+		percentiles = append(percentiles, q)
+	}
+	return percentiles, nil
+}
+
+// splitWeight splits the trailing weight field off a -weighted `station;temperature;weight`
+// line. Weights must be finite and non-negative.
+func splitWeight(line string, sep byte) (string, float64, error) {
+	lastSep := strings.LastIndexByte(line, sep)
+	if lastSep == -1 {
+		return "", 0, fmt.Errorf("could not parse line, the weight is missing: %s", line)
+	}
+	weight, err := strconv.ParseFloat(line[lastSep+1:], 64)
+	if err != nil || weight < 0 || math.IsInf(weight, 0) || math.IsNaN(weight) {
+		return "", 0, fmt.Errorf("invalid weight: %s", line)
+	}
+	return line[:lastSep], weight, nil
+}
+
+// addToHistogram records a reading in the station's histogram, creating it on first use.
+func (p *processor) addToHistogram(station string, temperature float64) {
+	h, exists := p.hists[station]
+	if !exists {
+		h = new(histogram)
+		p.hists[station] = h
+	}
+	h.add(temperature)
+}
+
+// addToWeightedHistogram records a weighted reading in the station's weighted histogram,
+// creating it on first use.
+func (p *processor) addToWeightedHistogram(station string, temperature, weight float64) {
+	h, exists := p.weighted[station]
+	if !exists {
+		h = new(weightedHistogram)
+		p.weighted[station] = h
+	}
+	h.add(temperature, weight)
+}
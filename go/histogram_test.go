@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestHistogram_Add tests bucketing of readings, including values past the covered range.
+func TestHistogram_Add(t *testing.T) {
+	var h histogram
+	h.add(12.3)
+	h.add(12.3)
+	h.add(-0.04) // rounds to 0.0
+	h.add(150.0) // clamped into the 99.9 bucket
+
+	require.Equal(t, int64(2), h[123-histogramMinTenths])
+	require.Equal(t, int64(1), h[0-histogramMinTenths])
+	require.Equal(t, int64(1), h[histogramBuckets-1])
+	require.Equal(t, 3, h.distinct())
+}
+
+// TestProcessLine_Distinct tests that -distinct counts the distinct temperatures per station.
+func TestProcessLine_Distinct(t *testing.T) {
+	p := newProcessor(options{distinct: true})
+
+	readings := []string{"10.0", "10.0", "12.5", "10.0", "-3.0", "12.5", "12.5", "-3.0", "10.0", "10.0"}
+	for _, temperature := range readings {
+		require.NoError(t, p.processLine("Berlin;"+temperature))
+	}
+	require.NoError(t, p.processLine("Hamburg;5.0"))
+
+	require.Equal(t, 3, p.hists["Berlin"].distinct())
+	require.Equal(t, 1, p.hists["Hamburg"].distinct())
+	require.Equal(t,
+		"{Berlin=-3.0/8.2/12.5 distinct=3, Hamburg=5.0/5.0/5.0 distinct=1}",
+		formatOutputWith(p.stats, outputOptions{annotate: p.annotate}),
+	)
+}
+
+// TestHistogram_Percentile tests nearest-rank percentiles over counts and over weights.
+func TestHistogram_Percentile(t *testing.T) {
+	var h histogram
+	var w weightedHistogram
+	for _, temperature := range []float64{1.0, 2.0, 3.0, 4.0} {
+		h.add(temperature)
+		w.add(temperature, 1)
+	}
+	for q, want := range map[float64]float64{1: 1.0, 25: 1.0, 50: 2.0, 75: 3.0, 90: 4.0, 100: 4.0} {
+		require.Equal(t, want, h.percentile(q), "p%v", q)
+		require.Equal(t, want, w.percentile(q), "weighted p%v", q)
+	}
+
+	w.add(1.0, 0.5)
+	require.Equal(t, 2.0, w.percentile(50), "1.5 of 4.5 is below 2.0")
+	require.True(t, math.IsNaN(new(histogram).percentile(50)))
+	require.True(t, math.IsNaN(new(weightedHistogram).percentile(50)))
+}
+
+// TestParsePercentiles tests the -percentiles list.
+func TestParsePercentiles(t *testing.T) {
+	percentiles, err := parsePercentiles("50, 90,99.9,100")
+	require.NoError(t, err)
+	require.Equal(t, []float64{50, 90, 99.9, 100}, percentiles)
+
+	for _, value := range []string{"", "0", "101", "50,", "median"} {
+		_, err = parsePercentiles(value)
+		require.ErrorContains(t, err, "invalid percentile", value)
+	}
+}
+
+// TestProcessLine_WeightedPercentiles tests that weights move the median towards the
+// heavily weighted reading.
+func TestProcessLine_WeightedPercentiles(t *testing.T) {
+	lines := []string{"Berlin;10.0;1", "Berlin;20.0;1", "Berlin;30.0;10"}
+
+	unweighted := newProcessor(options{percentiles: []float64{50}})
+	for _, line := range lines {
+		_, reading, _ := strings.Cut(line, ";")
+		reading, _, _ = strings.Cut(reading, ";")
+		require.NoError(t, unweighted.processLine("Berlin;"+reading))
+	}
+	weighted := newProcessor(options{percentiles: []float64{50}, weighted: true})
+	for _, line := range lines {
+		require.NoError(t, weighted.processLine(line))
+	}
+
+	require.Equal(t, "{Berlin=10.0/20.0/30.0 p50=20.0}", formatOutputWith(unweighted.stats, outputOptions{annotate: unweighted.annotate}))
+	require.Equal(t, "{Berlin=10.0/20.0/30.0 p50=30.0}", formatOutputWith(weighted.stats, outputOptions{annotate: weighted.annotate}),
+		"min, mean and max stay unweighted")
+
+	require.ErrorContains(t, weighted.processLine("Berlin;12.0;heavy"), "invalid weight")
+	require.ErrorContains(t, weighted.processLine("Berlin;12.0;-1"), "invalid weight")
+	require.ErrorContains(t, weighted.processLine("12.0"), "the weight is missing")
+}
+
+// TestProcessLine_Mode tests that -mode reports the most common temperature, breaking ties
+// towards the lowest value.
+func TestProcessLine_Mode(t *testing.T) {
+	p := newProcessor(options{mode: true})
+
+	for _, temperature := range []string{"10.0", "10.0", "12.5", "10.0", "-3.0", "10.0"} {
+		require.NoError(t, p.processLine("Berlin;"+temperature))
+	}
+	for _, temperature := range []string{"7.5", "-2.0", "7.5", "-2.0", "30.0"} {
+		require.NoError(t, p.processLine("Oslo;"+temperature))
+	}
+
+	require.Equal(t, 10.0, p.hists["Berlin"].mode())
+	require.Equal(t, -2.0, p.hists["Oslo"].mode(), "a tie goes to the lowest temperature")
+	require.Equal(t,
+		"{Berlin=-3.0/8.2/12.5 mode=10.0, Oslo=-2.0/8.2/30.0 mode=-2.0}",
+		formatOutputWith(p.stats, outputOptions{annotate: p.annotate}),
+	)
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_WeightedPercentiles tests -percentiles with and without -weighted end to end.
+func TestRun_WeightedPercentiles(t *testing.T) {
+	file := createTestFile(t, "Berlin;10.0;1\nBerlin;20.0;1\nBerlin;30.0;10\nOslo;-5.0;2\nOslo;5.0;1\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-percentiles", "50,90", "-weighted", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=10.0/20.0/30.0 p50=30.0 p90=30.0, Oslo=-5.0/0.0/5.0 p50=-5.0 p90=5.0}\n\n", stdout.String())
+
+	err := run([]string{"-weighted", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-weighted requires -percentiles")
+}
+
+// TestRun_WeightedTee tests that -tee copies the lines with their weights.
+func TestRun_WeightedTee(t *testing.T) {
+	input := "Berlin;10.0;1\nBerlin;30.0;10\nOslo;-5.0;2\n"
+	file := createTestFile(t, input)
+	defer cleanupTestFile(t, file)
+	tee := filepath.Join(t.TempDir(), "clean.txt")
+
+	require.NoError(t, run([]string{"-percentiles", "50", "-weighted", "-tee", tee, file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{}))
+	data, err := os.ReadFile(tee)
+	require.NoError(t, err)
+	require.Equal(t, input, string(data))
+}
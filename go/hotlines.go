@@ -0,0 +1,63 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// hotLines counts how often each exact line occurs, for -profile-hotlines. It holds every
+// distinct line once, as the key of its count.
+type hotLines struct {
+	counts map[string]int64
+}
+
+// hotLine is a distinct line and how many times it occurred.
+type hotLine struct {
+	line  string
+	count int64
+}
+
+// newHotLines creates an empty line counter.
+func newHotLines() *hotLines {
+	return &hotLines{counts: make(map[string]int64)}
+}
+
+// add counts one occurrence of line.
+func (h *hotLines) add(line string) {
+	h.counts[line]++
+}
+
+// merge adds the counts of other into h.
+func (h *hotLines) merge(other *hotLines) {
+	for line, count := range other.counts {
+		h.counts[line] += count
+	}
+}
+
+// top returns the k most frequent lines, most frequent first and ties in byte-wise order.
+func (h *hotLines) top(k int) []hotLine {
+	all := make([]hotLine, 0, len(h.counts))
+	for line, count := range h.counts {
+		all = append(all, hotLine{line, count})
+	}
+	slices.SortFunc(all, func(a, b hotLine) int {
+		if c := cmp.Compare(b.count, a.count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.line, b.line)
+	})
+	return all[:min(k, len(all))]
+}
+
+// writeHotLines writes the k most frequent lines to w as `uniq -c` does: the count
+// right-aligned, then the line.
+func writeHotLines(w io.Writer, h *hotLines, k int) error {
+	for _, e := range h.top(k) {
+		if _, err := fmt.Fprintf(w, "%7d %s\n", e.count, e.line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
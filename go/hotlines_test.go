@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestHotLines_Top tests the ranking by count, with ties in byte-wise order, and merging.
+func TestHotLines_Top(t *testing.T) {
+	h := newHotLines()
+	for _, line := range []string{"b;1.0", "a;1.0", "c;1.0", "c;1.0", "b;1.0", "c;1.0", "d;1.0"} {
+		h.add(line)
+	}
+	require.Equal(t, []hotLine{{"c;1.0", 3}, {"b;1.0", 2}, {"a;1.0", 1}}, h.top(3))
+	require.Len(t, h.top(10), 4)
+
+	other := newHotLines()
+	other.add("a;1.0")
+	other.add("a;1.0")
+	other.add("e;1.0")
+	h.merge(other)
+	require.Equal(t, []hotLine{{"a;1.0", 3}, {"c;1.0", 3}}, h.top(2))
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_ProfileHotlines tests that a line repeated many times is reported as the top
+// hotline with its count, the lines differing only in spacing being counted apart.
+func TestRun_ProfileHotlines(t *testing.T) {
+	var input strings.Builder
+	for range 50 {
+		input.WriteString("Hamburg;12.0\nBerlin;20.0\n")
+	}
+	input.WriteString(strings.Repeat("Hamburg;12.0\n", 25))
+	input.WriteString("Oslo;-5.0\nOslo; -5.0\n")
+	file := createTestFile(t, input.String())
+	defer cleanupTestFile(t, file)
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"-profile-hotlines", "3", "-sanitize", file.Name()}, nil, &stdout, &stderr))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=12.0/12.0/12.0, Oslo=-5.0/-5.0/-5.0}\n\n", stdout.String())
+	require.Equal(t, "     75 Hamburg;12.0\n     50 Berlin;20.0\n      1 Oslo; -5.0\n", stderr.String())
+}
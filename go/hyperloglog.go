@@ -0,0 +1,100 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// Bounds and default of the -hll-precision, the number of index bits of the sketch.
+const (
+	minHLLPrecision     = 4
+	maxHLLPrecision     = 18
+	defaultHLLPrecision = 14 // 16 KiB of registers, a standard error of 0.81%
+)
+
+// hyperLogLog estimates the number of distinct stations in a fixed 2^precision bytes,
+// however many there are, for -approx-cardinality.
+//
+// Each station is hashed once: the top precision bits select a register, which keeps the
+// highest position of the first set bit seen among the remaining bits. The estimate is
+// the bias-corrected harmonic mean of the registers (Flajolet et al.), with linear
+// counting for small cardinalities. Sketches of the same precision merge losslessly by
+// taking the register-wise max.
+type hyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+// newHyperLogLog creates an empty sketch of the given precision, which must be between
+// minHLLPrecision and maxHLLPrecision.
+func newHyperLogLog(precision uint8) *hyperLogLog {
+	return &hyperLogLog{precision: precision, registers: make([]uint8, 1<<precision)}
+}
+
+// add records station.
+func (h *hyperLogLog) add(station string) {
+	hash := hashStation(station)
+	index := hash >> (64 - h.precision)
+	rest := hash<<h.precision | 1<<(h.precision-1) // the sentinel bounds the rank
+	rank := uint8(bits.LeadingZeros64(rest)) + 1
+	h.registers[index] = max(h.registers[index], rank)
+}
+
+// merge folds other, which must have the same precision, into h.
+func (h *hyperLogLog) merge(other *hyperLogLog) {
+	for i, rank := range other.registers {
+		h.registers[i] = max(h.registers[i], rank)
+	}
+}
+
+// estimate returns the approximate number of distinct stations added.
+func (h *hyperLogLog) estimate() uint64 {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, rank := range h.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	estimate := hllAlpha(len(h.registers)) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros)) // linear counting is more accurate here
+	}
+	return uint64(math.Round(estimate))
+}
+
+// standardError returns the relative standard error of the estimate, 1.04/sqrt(m).
+func (h *hyperLogLog) standardError() float64 {
+	return 1.04 / math.Sqrt(float64(len(h.registers)))
+}
+
+// hllAlpha is the bias correction constant for m registers.
+func hllAlpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079/float64(m))
+	}
+}
+
+// hashStation hashes a station name to 64 well-mixed bits: FNV-1a spreads poorly into the
+// high bits the sketch indexes by, so it is followed by the murmur3 finalizer.
+func hashStation(station string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(station))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
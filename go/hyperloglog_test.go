@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestHyperLogLog_Estimate tests that estimates of known cardinalities, small and large,
+// stay within three standard errors, and that duplicates don't count.
+func TestHyperLogLog_Estimate(t *testing.T) {
+	for _, precision := range []uint8{minHLLPrecision, 10, defaultHLLPrecision} {
+		for _, n := range []int{100, 10_000, 200_000} {
+			h := newHyperLogLog(precision)
+			for i := range n {
+				h.add(fmt.Sprintf("station-%d", i))
+				h.add(fmt.Sprintf("station-%d", i/2)) // a duplicate
+			}
+			relative := math.Abs(float64(h.estimate())-float64(n)) / float64(n)
+			require.LessOrEqual(t, relative, 3*h.standardError(), "precision=%d n=%d estimate=%d", precision, n, h.estimate())
+		}
+	}
+
+	require.Zero(t, newHyperLogLog(defaultHLLPrecision).estimate())
+}
+
+// TestHyperLogLog_Merge tests that merging the sketches of two halves equals the sketch
+// of the whole.
+func TestHyperLogLog_Merge(t *testing.T) {
+	whole, first, second := newHyperLogLog(10), newHyperLogLog(10), newHyperLogLog(10)
+	for i := range 5000 {
+		station := "station-" + strconv.Itoa(i)
+		whole.add(station)
+		if i%3 == 0 {
+			first.add(station)
+		} else {
+			second.add(station)
+		}
+	}
+
+	first.merge(second)
+	require.Equal(t, whole.registers, first.registers)
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_ApproxCardinality tests -approx-cardinality on a file with a known number of
+// distinct stations, on the default and the chunked paths.
+func TestRun_ApproxCardinality(t *testing.T) {
+	const distinct = 20_000
+	var data strings.Builder
+	for i := range 2 * distinct {
+		fmt.Fprintf(&data, "station-%d;%d.5\n", i%distinct, i%50)
+	}
+	file := createTestFile(t, data.String())
+	defer cleanupTestFile(t, file)
+
+	output := regexp.MustCompile(`^approx-stations=(\d+) error=±0\.81%\n$`)
+	for _, args := range [][]string{{"-approx-cardinality"}, {"-approx-cardinality", "-workers", "4"}} {
+		var stdout bytes.Buffer
+		require.NoError(t, run(append(args, file.Name()), nil, &stdout, &bytes.Buffer{}), "%v", args)
+		match := output.FindStringSubmatch(stdout.String())
+		require.NotNil(t, match, stdout.String())
+		estimate, err := strconv.Atoi(match[1])
+		require.NoError(t, err)
+		require.InEpsilon(t, distinct, estimate, 3*0.0081, "%v", args)
+	}
+
+	err := run([]string{"-approx-cardinality", "-hll-precision", "3", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.EqualError(t, err, "-hll-precision must be between 4 and 18")
+	err = run([]string{"-approx-cardinality", "-summary", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-approx-cardinality only prints the estimate")
+}
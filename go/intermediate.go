@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The intermediate format is a lossless text dump of a Result, one station per line:
+//
+//	station;min;sum;count;max
+//
+// Floats are written with the shortest representation that round-trips exactly, so a
+// result read back with ReadIntermediate merges as if it had never left memory. Fields
+// are parsed from the right, so station names may themselves contain ';'.
+
+// WriteIntermediate writes the result in the intermediate format, sorted by station name.
+func WriteIntermediate(w io.Writer, result Result) error {
+	stations := make([]string, 0, len(result))
+	for station := range result {
+		stations = append(stations, station)
+	}
+	sort.Strings(stations)
+
+	bw := bufio.NewWriter(w)
+	for _, station := range stations {
+		s := result[station]
+		_, err := fmt.Fprintf(bw, "%s;%s;%s;%d;%s\n", station,
+			strconv.FormatFloat(s.Min, 'g', -1, 64),
+			strconv.FormatFloat(s.Sum, 'g', -1, 64),
+			s.Count,
+			strconv.FormatFloat(s.Max, 'g', -1, 64),
+		)
+		if err != nil {
+			return fmt.Errorf("could not write intermediate: %w", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf("could not write intermediate: %w", err)
+	}
+	return nil
+}
+
+// ReadIntermediate reads a result written by WriteIntermediate.
+func ReadIntermediate(r io.Reader) (Result, error) {
+	result := make(Result)
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		station, s, err := parseIntermediateLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse intermediate line %d: %w", lineNo, err)
+		}
+		result[station] = result[station].merge(s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read intermediate: %w", err)
+	}
+
+	return result, nil
+}
+
+// parseIntermediateLine parses a single `station;min;sum;count;max` line.
+func parseIntermediateLine(line string) (string, Stats, error) {
+	var fields [4]string
+	for i := len(fields) - 1; i >= 0; i-- {
+		semicolon := strings.LastIndexByte(line, ';')
+		if semicolon == -1 {
+			return "", Stats{}, errors.New("expected 5 ';'-separated fields")
+		}
+		fields[i] = line[semicolon+1:]
+		line = line[:semicolon]
+	}
+
+	var s Stats
+	var err error
+	if s.Min, err = strconv.ParseFloat(fields[0], 64); err != nil {
+		return "", Stats{}, fmt.Errorf("invalid min: %w", err)
+	}
+	if s.Sum, err = strconv.ParseFloat(fields[1], 64); err != nil {
+		return "", Stats{}, fmt.Errorf("invalid sum: %w", err)
+	}
+	if s.Count, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return "", Stats{}, fmt.Errorf("invalid count: %w", err)
+	}
+	if s.Max, err = strconv.ParseFloat(fields[3], 64); err != nil {
+		return "", Stats{}, fmt.Errorf("invalid max: %w", err)
+	}
+
+	return line, s, nil
+}
+
+// appendIntermediate merges result into the intermediate file at path, creating it if it
+// doesn't exist yet. The merged file is written next to the original and renamed over it,
+// so an interrupted run never leaves a truncated intermediate behind.
+func appendIntermediate(path string, result Result) error {
+	prior := make(Result)
+
+	file, err := os.Open(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		// Nothing persisted yet, the prior result is empty.
+	case err != nil:
+		return fmt.Errorf("could not open intermediate: %w", err)
+	default:
+		prior, err = ReadIntermediate(file)
+		_ = file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	prior.Merge(result)
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("could not create intermediate: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }() // no-op once renamed
+
+	if err = WriteIntermediate(tmp, prior); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("could not write intermediate: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not replace intermediate: %w", err)
+	}
+
+	return nil
+}
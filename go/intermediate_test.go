@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestIntermediate_RoundTrip tests that WriteIntermediate and ReadIntermediate are lossless.
+func TestIntermediate_RoundTrip(t *testing.T) {
+	original := Result{
+		"Berlin":   {Min: -3.2, Sum: 0.30000000000000004, Count: 3, Max: 25.0},
+		"Semi;Way": {Min: 1.5, Sum: 3.0, Count: 2, Max: 1.5},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteIntermediate(&buf, original))
+	require.Equal(t, "Berlin;-3.2;0.30000000000000004;3;25\nSemi;Way;1.5;3;2;1.5\n", buf.String())
+
+	decoded, err := ReadIntermediate(&buf)
+	require.NoError(t, err)
+	require.Equal(t, original, decoded)
+}
+
+// TestReadIntermediate_Invalid tests that malformed lines report their line number.
+func TestReadIntermediate_Invalid(t *testing.T) {
+	_, err := ReadIntermediate(strings.NewReader("Berlin;1;2;3;4\nHamburg;1;2;three;4\n"))
+	require.ErrorContains(t, err, "line 2: invalid count")
+
+	_, err = ReadIntermediate(strings.NewReader("Berlin;1;2\n"))
+	require.ErrorContains(t, err, "line 1")
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_AppendOutput tests that two runs with -append-output accumulate into one intermediate.
+func TestRun_AppendOutput(t *testing.T) {
+	intermediate := filepath.Join(t.TempDir(), "stats.txt")
+
+	first := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\n")
+	defer cleanupTestFile(t, first)
+	second := createTestFile(t, "Hamburg;8.0\nOslo;-5.0\nBerlin;25.0\n")
+	defer cleanupTestFile(t, second)
+
+	for _, file := range []*os.File{first, second} {
+		var stdout bytes.Buffer
+		require.NoError(t, run([]string{"-append-output", intermediate, file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	}
+
+	data, err := os.ReadFile(intermediate)
+	require.NoError(t, err)
+
+	result, err := ReadIntermediate(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, Result{
+		"Berlin":  {Min: 20.0, Sum: 45.0, Count: 2, Max: 25.0},
+		"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+		"Oslo":    {Min: -5.0, Sum: -5.0, Count: 1, Max: -5.0},
+	}, result)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Formats of the input lines, selected with -format-in.
+const (
+	inputText  = "text"
+	inputJSONL = "jsonl"
+)
+
+// jsonMeasurement is a -format-in jsonl line. The fields are pointers so missing ones can
+// be told apart from empty names and zero temperatures.
+type jsonMeasurement struct {
+	Station *string  `json:"station"`
+	Temp    *float64 `json:"temp"`
+}
+
+// parseJSONLine decodes a JSON-lines measurement such as {"station":"Berlin","temp":12.0}.
+// Other fields are ignored.
+func parseJSONLine(line string) (string, float64, error) {
+	var m jsonMeasurement
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		return "", 0, fmt.Errorf("could not parse JSON line: %w", err)
+	}
+	if m.Station == nil || m.Temp == nil {
+		return "", 0, fmt.Errorf("could not parse line, station or temp is missing: %s", line)
+	}
+	return *m.Station, *m.Temp, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestParseJSONLine tests decoding JSON-lines measurements and rejecting incomplete ones.
+func TestParseJSONLine(t *testing.T) {
+	station, temperature, err := parseJSONLine(`{"station":"St. John's;NL","temp":-3.5,"unit":"C"}`)
+	require.NoError(t, err)
+	require.Equal(t, "St. John's;NL", station)
+	require.Equal(t, -3.5, temperature)
+
+	station, temperature, err = parseJSONLine(`{"temp":0,"station":""}`)
+	require.NoError(t, err, "zero values are present")
+	require.Empty(t, station)
+	require.Zero(t, temperature)
+
+	_, _, err = parseJSONLine(`{"station":"Berlin"}`)
+	require.ErrorContains(t, err, "station or temp is missing")
+	_, _, err = parseJSONLine(`{"station":"Berlin","temp":"warm"}`)
+	require.ErrorContains(t, err, "could not parse JSON line")
+	_, _, err = parseJSONLine("Berlin;12.0")
+	require.ErrorContains(t, err, "could not parse JSON line")
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_JSONLInput tests that -format-in jsonl aggregates the same stats as the
+// equivalent semicolon input.
+func TestRun_JSONLInput(t *testing.T) {
+	text := createTestFile(t, "Hamburg;12.0\nBerlin;-3.5\nHamburg;8.0\n")
+	defer cleanupTestFile(t, text)
+	jsonl := createTestFile(t, `{"station":"Hamburg","temp":12.0}`+"\n"+
+		`{"station":"Berlin","temp":-3.5}`+"\r\n\n"+
+		`{"temp":8,"station":"Hamburg"}`)
+	defer cleanupTestFile(t, jsonl)
+
+	var want, got bytes.Buffer
+	require.NoError(t, run([]string{text.Name()}, nil, &want, &bytes.Buffer{}))
+	require.NoError(t, run([]string{"-format-in", "jsonl", "-assume-ascii", jsonl.Name()}, nil, &got, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=-3.5/-3.5/-3.5, Hamburg=8.0/10.0/12.0}\n\n", got.String())
+	require.Equal(t, want.String(), got.String())
+
+	err := run([]string{"-format-in", "jsonl", "-sep", ",", jsonl.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-format-in jsonl can't be combined with")
+	err = run([]string{"-format-in", "csv", jsonl.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.EqualError(t, err, `-format-in must be text, jsonl or tar, got "csv"`)
+}
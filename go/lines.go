@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// ForEachLine memory-maps the file at path and calls fn with the station and temperature
+// of every non-empty `station;temperature` line, in file order. It stops at the first
+// error, either a malformed line or one returned by fn, and returns it. As in the CLI, a
+// temperature outside [-99.9, 99.9] is an error and a NaN one is skipped.
+//
+// This exposes the parsing engine without the aggregation, e.g. to feed the raw readings
+// into a database.
+//
+// station points into the memory mapping and is only valid for the duration of the call:
+// it must be copied (e.g. with string(station)) to be retained, and must not be modified.
+func ForEachLine(path string, fn func(station []byte, temp float64) error) error {
+	return ForEachLineNum(path, func(_ int, station []byte, temp float64) error {
+		return fn(station, temp)
+	})
+}
+
+// ForEachLineNum is ForEachLine with the 1-based line number of each reading passed to
+// fn. Blank lines are skipped but still counted, so lineNo always matches the file, and
+// parse errors are reported with their line number.
+//
+// The scan is single-threaded to keep line numbers sequential.
+func ForEachLineNum(path string, fn func(lineNo int, station []byte, temp float64) error) error {
+	return scanLines(path, func(lineNo int, line []byte) error {
+		station, temperature, err := parseMeasurement(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if math.IsNaN(temperature) {
+			return nil // see processLine
+		}
+		return fn(lineNo, station, temperature)
+	})
+}
+
+// LookupStation scans the file at path and aggregates only the readings of the station
+// called name. Other lines are matched on their station without parsing the temperature,
+// and nothing is kept for them, so it is faster and leaner than a full aggregation when a
+// single station is needed. Its readings are checked like ForEachLine's. The boolean is
+// false if the station doesn't appear.
+func LookupStation(path, name string) (Stats, bool, error) {
+	var stats Stats
+	err := scanLines(path, func(lineNo int, line []byte) error {
+		lastSemicolon := bytes.LastIndexByte(line, ';')
+		if lastSemicolon == -1 {
+			return fmt.Errorf("line %d: could not parse line: %s", lineNo, line)
+		}
+		if string(line[:lastSemicolon]) != name { // the conversion doesn't allocate for a comparison
+			return nil
+		}
+
+		temperature, err := parseTemperature(line, lastSemicolon)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if math.IsNaN(temperature) {
+			return nil // see processLine
+		}
+		stats = stats.merge(Stats{Min: temperature, Sum: temperature, Count: 1, Max: temperature})
+		return nil
+	})
+	if err != nil {
+		return Stats{}, false, err
+	}
+	return stats, stats.Count > 0, nil
+}
+
+// scanLines memory-maps the file at path and calls fn with every non-empty line and its
+// 1-based line number, stopping at the first error fn returns. Lines may end in LF or CRLF,
+// and fn never sees the CR.
+func scanLines(path string, fn func(lineNo int, line []byte) error) (err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	if info, statErr := file.Stat(); statErr == nil && info.Size() == 0 {
+		return nil // an empty file can't be memory-mapped
+	}
+
+	mmap, err := mmapFile(file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unmapErr := syscall.Munmap(mmap); unmapErr != nil && err == nil {
+			err = fmt.Errorf("could not unmap memory: %w", unmapErr)
+		}
+	}()
+
+	lineNo := 0
+	for data := mmap; len(data) > 0; {
+		var line []byte
+		if newline := bytes.IndexByte(data, '\n'); newline == -1 {
+			line, data = data, nil // last line without a trailing newline
+		} else {
+			line, data = data[:newline], data[newline+1:]
+		}
+		line = bytes.TrimSuffix(line, []byte{'\r'}) // a CRLF line ending, like processFile accepts
+		lineNo++
+		if len(line) == 0 {
+			continue
+		}
+
+		if err = fn(lineNo, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseMeasurement splits a `station;temperature` line at its last semicolon and parses
+// the temperature with parseTemperature. The returned station aliases line.
+func parseMeasurement(line []byte) ([]byte, float64, error) {
+	lastSemicolon := bytes.LastIndexByte(line, ';')
+	if lastSemicolon == -1 {
+		return nil, 0, fmt.Errorf("could not parse line: %s", line)
+	}
+
+	temperature, err := parseTemperature(line, lastSemicolon)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return line[:lastSemicolon], temperature, nil
+}
+
+// parseTemperature parses the temperature following the separator at sep in line, failing
+// on one outside [minTemperature, maxTemperature] like processLine. NaN is returned as is.
+func parseTemperature(line []byte, sep int) (float64, error) {
+	temperature, err := strconv.ParseFloat(string(line[sep+1:]), 64)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse temperature: %w", err)
+	}
+	if outOfRange(temperature) {
+		return 0, fmt.Errorf("temperature out of range: %s", line)
+	}
+	return temperature, nil
+}
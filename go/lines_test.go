@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestParseMeasurement tests splitting a line into station and temperature.
+func TestParseMeasurement(t *testing.T) {
+	station, temperature, err := parseMeasurement([]byte("St. John's;-3.5"))
+	require.NoError(t, err)
+	require.Equal(t, "St. John's", string(station))
+	require.InDelta(t, -3.5, temperature, 1e-9)
+
+	_, _, err = parseMeasurement([]byte("no separator"))
+	require.EqualError(t, err, "could not parse line: no separator")
+
+	_, _, err = parseMeasurement([]byte("Berlin;warm"))
+	require.ErrorContains(t, err, "could not parse temperature")
+
+	_, _, err = parseMeasurement([]byte("Berlin;999.0"))
+	require.EqualError(t, err, "temperature out of range: Berlin;999.0")
+
+	_, _, err = parseMeasurement([]byte("Berlin;-Inf"))
+	require.EqualError(t, err, "temperature out of range: Berlin;-Inf")
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestForEachLine tests that every parsed reading is passed to the callback in file order.
+func TestForEachLine(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;-3.2\n\n北京;25.5")
+	defer cleanupTestFile(t, file)
+
+	type reading struct {
+		station string
+		temp    float64
+	}
+	var readings []reading
+	err := ForEachLine(file.Name(), func(station []byte, temp float64) error {
+		readings = append(readings, reading{string(station), temp})
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []reading{{"Hamburg", 12.0}, {"Berlin", -3.2}, {"北京", 25.5}}, readings)
+}
+
+// TestForEachLine_StopsOnError tests that an error returned by the callback stops the scan.
+func TestForEachLine_StopsOnError(t *testing.T) {
+	file := createTestFile(t, "A;1.0\nB;2.0\nC;3.0\n")
+	defer cleanupTestFile(t, file)
+
+	errStop := errors.New("stop")
+	calls := 0
+	err := ForEachLine(file.Name(), func(station []byte, temp float64) error {
+		calls++
+		if string(station) == "B" {
+			return errStop
+		}
+		return nil
+	})
+
+	require.ErrorIs(t, err, errStop)
+	require.Equal(t, 2, calls)
+}
+
+// TestForEachLine_Malformed tests that a malformed line is reported as an error.
+func TestForEachLine_Malformed(t *testing.T) {
+	file := createTestFile(t, "A;1.0\ngarbage\n")
+	defer cleanupTestFile(t, file)
+
+	err := ForEachLine(file.Name(), func([]byte, float64) error { return nil })
+	require.EqualError(t, err, "line 2: could not parse line: garbage")
+}
+
+// TestForEachLineNum tests that line numbers are sequential and count skipped blank lines.
+func TestForEachLineNum(t *testing.T) {
+	file := createTestFile(t, "A;1.0\n\nB;2.0\n\n\nC;3.0")
+	defer cleanupTestFile(t, file)
+
+	var lineNos []int
+	var stations []string
+	err := ForEachLineNum(file.Name(), func(lineNo int, station []byte, temp float64) error {
+		lineNos = append(lineNos, lineNo)
+		stations = append(stations, string(station))
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, []int{1, 3, 6}, lineNos)
+	require.Equal(t, []string{"A", "B", "C"}, stations)
+}
+
+// TestForEachLineNum_Empty tests that an empty file calls nothing.
+func TestForEachLineNum_Empty(t *testing.T) {
+	file := createTestFile(t, "")
+	defer cleanupTestFile(t, file)
+
+	err := ForEachLineNum(file.Name(), func(int, []byte, float64) error {
+		t.Fatal("unexpected call")
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+// TestLookupStation tests the stats of a present station, including one whose name is a
+// prefix of another, and the result for an absent one.
+func TestLookupStation(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\nHamburg Nord;40.0\nBerlin;x\n")
+	defer cleanupTestFile(t, file)
+
+	stats, found, err := LookupStation(file.Name(), "Hamburg")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, Stats{Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0}, stats)
+
+	stats, found, err = LookupStation(file.Name(), "Oslo")
+	require.NoError(t, err)
+	require.False(t, found)
+	require.Zero(t, stats)
+
+	_, _, err = LookupStation(file.Name(), "Berlin")
+	require.EqualError(t, err, `line 5: could not parse temperature: strconv.ParseFloat: parsing "x": invalid syntax`)
+}
+
+// TestScanLines_CRLF tests that the line API accepts CRLF line endings, including on the
+// last line and on blank lines.
+func TestScanLines_CRLF(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\r\n\r\nBerlin;-3.2\r\nHamburg;8.0\r")
+	defer cleanupTestFile(t, file)
+
+	var lineNos []int
+	var temps []float64
+	err := ForEachLineNum(file.Name(), func(lineNo int, station []byte, temp float64) error {
+		lineNos = append(lineNos, lineNo)
+		temps = append(temps, temp)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 3, 4}, lineNos)
+	require.Equal(t, []float64{12.0, -3.2, 8.0}, temps)
+
+	stats, found, err := LookupStation(file.Name(), "Hamburg")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, Stats{Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0}, stats)
+}
+
+// TestForEachLine_Validation tests that the line API checks readings like the CLI: NaN is
+// skipped and an out-of-range temperature fails, also for LookupStation.
+func TestForEachLine_Validation(t *testing.T) {
+	file := createTestFile(t, "Berlin;NaN\nBerlin;12.0\nOslo;999.0\n")
+	defer cleanupTestFile(t, file)
+
+	var temps []float64
+	err := ForEachLine(file.Name(), func(station []byte, temp float64) error {
+		temps = append(temps, temp)
+		return nil
+	})
+	require.EqualError(t, err, "line 3: temperature out of range: Oslo;999.0")
+	require.Equal(t, []float64{12.0}, temps)
+
+	stats, found, err := LookupStation(file.Name(), "Berlin")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, Stats{Min: 12.0, Sum: 12.0, Count: 1, Max: 12.0}, stats)
+
+	_, _, err = LookupStation(file.Name(), "Oslo")
+	require.EqualError(t, err, "line 3: temperature out of range: Oslo;999.0")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+)
+
+// listenCommand is the subcommand that aggregates measurements received over TCP.
+const listenCommand = "listen"
+
+// runListen implements `listen -addr :9000`: it accepts TCP connections one at a time,
+// aggregates the newline-delimited measurements sent over each until EOF and prints
+// the result for that connection.
+func runListen(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet(listenCommand, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	addr := fs.String("addr", ":9000", "TCP `address` to listen on")
+	verbose := fs.Bool("v", false, "log connections to stderr")
+	readTimeout := fs.Duration("read-timeout", 0, "drop a connection that sends no data for `duration` (0 = wait forever)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	verbosity := 0
+	if *verbose {
+		verbosity = 1
+	}
+	logger := newLogger(stderr, verbosity)
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("could not listen: %w", err)
+	}
+	defer func() { _ = ln.Close() }()
+	logger.Info("listening", "addr", ln.Addr().String())
+
+	return serve(ln, options{readTimeout: *readTimeout}, stdout, stderr, logger)
+}
+
+// serve handles connections from ln sequentially until ln is closed. A connection that
+// fails is reported on stderr, whatever the verbosity, and dropped without stopping the server.
+func serve(ln net.Listener, opts options, stdout, stderr io.Writer, logger *slog.Logger) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return fmt.Errorf("could not accept connection: %w", err)
+		}
+
+		if err = handleConn(conn, opts, stdout, logger); err != nil {
+			fmt.Fprintf(stderr, "connection from %s failed: %v\n", conn.RemoteAddr(), err)
+		}
+	}
+}
+
+// handleConn aggregates everything read from conn and prints the result to stdout.
+func handleConn(conn net.Conn, opts options, stdout io.Writer, logger *slog.Logger) error {
+	defer func() { _ = conn.Close() }()
+	logger.Info("accepted connection", "remote", conn.RemoteAddr().String())
+
+	p := newProcessor(opts)
+	p.logger = logger
+	if err := p.processReader(p.withReadTimeout(conn)); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintln(stdout, formatOutput(p.stats))
+	return err
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestHandleConn tests aggregating the lines sent over a single connection.
+func TestHandleConn(t *testing.T) {
+	client, server := net.Pipe()
+	go func() {
+		_, _ = io.WriteString(client, "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0")
+		_ = client.Close()
+	}()
+
+	var stdout bytes.Buffer
+	require.NoError(t, handleConn(server, options{}, &stdout, slog.New(slog.DiscardHandler)))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n", stdout.String())
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestServe_Loopback tests serving sequential connections on a real loopback listener, one of
+// them failing.
+func TestServe_Loopback(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	results, stdout := io.Pipe()
+	done := make(chan error, 1)
+	var stderr bytes.Buffer
+	go func() { done <- serve(ln, options{}, stdout, &stderr, slog.New(slog.DiscardHandler)) }()
+
+	lines := bufio.NewReader(results)
+	for _, tc := range []struct{ input, expected string }{
+		{"Oslo;-5.0\nOslo;-10.0\nOslo;-2.0\n", "{Oslo=-10.0/-5.7/-2.0}\n"},
+		{"garbage\n", ""}, // fails without output, the server carries on
+		{"Tokyo;25.5\n", "{Tokyo=25.5/25.5/25.5}\n"},
+	} {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		require.NoError(t, err)
+		_, err = io.WriteString(conn, tc.input)
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+		if tc.expected == "" {
+			continue
+		}
+
+		output, err := lines.ReadString('\n')
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, output)
+	}
+
+	require.NoError(t, ln.Close())
+	require.NoError(t, <-done)
+	require.Regexp(t, `^connection from 127\.0\.0\.1:\d+ failed: could not parse line: garbage\n$`, stderr.String(),
+		"reported without -v")
+}
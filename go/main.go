@@ -1,81 +1,1273 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"maps"
 	"math"
+	"math/rand/v2"
 	"os"
-	"sort"
+	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
+	"unicode/utf8"
 )
 
 const defaultFilePath = "../measurements.txt"
 
+// defaultOutputBuffer is the default size of the buffer results are written through.
+const defaultOutputBuffer = 64 << 10
+
 func main() {
-	filePath := defaultFilePath
-	if len(os.Args) > 1 {
-		filePath = os.Args[1]
+	os.Exit(runMain(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// runMain runs the command and returns the process exit code. A failure is reported on
+// stderr as a single-line JSON object, `{"error":"...","path":"..."}`, so automation can
+// consume it; path is the input being processed, or empty if the failure isn't tied to one.
+func runMain(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	err := run(args, stdin, stdout, stderr)
+	if err == nil {
+		return 0
+	}
+
+	report := struct {
+		Error string `json:"error"`
+		Path  string `json:"path"`
+	}{Error: err.Error()}
+	var inErr *inputError
+	if errors.As(err, &inErr) {
+		report.Path = inErr.path
+	}
+	_ = json.NewEncoder(stderr).Encode(report)
+	return 1
+}
+
+// inputError attaches the path of the input being processed to an error.
+type inputError struct {
+	path string
+	err  error
+}
+
+func (e *inputError) Error() string { return e.err.Error() }
+func (e *inputError) Unwrap() error { return e.err }
+
+// run parses the command-line arguments, processes the measurements file (or stdin when
+// the path is "-") and writes the formatted result to stdout. A leading subcommand name
+// dispatches to that subcommand instead.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) (err error) {
+	if len(args) > 0 {
+		switch args[0] {
+		case listenCommand:
+			return runListen(args[1:], stdout, stderr)
+		case benchCommand:
+			return runBench(args[1:], stdout, stderr)
+		case mergeIntermediatesCommand:
+			return runMergeIntermediates(args[1:], stdout, stderr)
+		case compareCommand:
+			return runCompare(args[1:], stdout, stderr)
+		}
+	}
+
+	start := time.Now()
+	cfg, err := parseFlags(args, stderr)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if cfg.isTarInput() && (cfg.workers > 0 || cfg.checkpointPath != "" || cfg.opts.retryOnFault || cfg.sepAuto || cfg.detectPrecision) {
+		return errors.New("tar input can't be combined with -workers, -checkpoint, -retry-on-sigbus, -sep auto or -decimal-places-detect")
+	}
+	if cfg.sepAuto {
+		switch {
+		case cfg.filePath == stdinPath:
+			// Sniffed by processStdin, behind the -read-timeout guard and the gzip decompression.
+		case isDir(cfg.filePath):
+			return errors.New("-sep auto requires a single input file or stdin")
+		default:
+			cfg.opts.sep, err = sniffFileSeparator(cfg.filePath)
+		}
+		if err != nil {
+			return &inputError{path: cfg.filePath, err: err}
+		}
+	}
+	if cfg.detectPrecision {
+		if cfg.filePath == stdinPath || isDir(cfg.filePath) {
+			return errors.New("-decimal-places-detect requires a single input file")
+		}
+		if cfg.output.decimals, err = detectFilePrecision(cfg.filePath, cfg.opts.separator()); err != nil {
+			return &inputError{path: cfg.filePath, err: err}
+		}
+		cfg.output.decimalsSet = true
+	}
+	if cfg.allowlistPath != "" {
+		if cfg.opts.allowlist, err = loadStationSet(cfg.allowlistPath); err != nil {
+			return err
+		}
+	}
+	if cfg.blocklistPath != "" {
+		if cfg.opts.blocklist, err = loadStationSet(cfg.blocklistPath); err != nil {
+			return err
+		}
+	}
+
+	// Results are written through a buffer that is flushed on every return path, so
+	// output already produced (e.g. by -sorted-input) isn't lost when a later step fails.
+	terminal := stdout
+	buffered := bufio.NewWriterSize(stdout, cfg.outputBuffer)
+	defer func() {
+		if flushErr := buffered.Flush(); flushErr != nil && err == nil {
+			err = fmt.Errorf("could not write output: %w", flushErr)
+		}
+	}()
+	stdout = buffered
+
+	if cfg.offsets > 0 {
+		return writeChunkOffsets(stdout, cfg.filePath, cfg.offsets)
+	}
+
+	logger := newLogger(stderr, cfg.verbosity)
+	logger.Info("random seed", "seed", cfg.opts.seed)
+	if cfg.sepAuto && cfg.filePath != stdinPath {
+		logger.Info("detected separator", "sep", string(cfg.opts.sep))
+	}
+
+	p := newProcessor(cfg.opts)
+	p.logger = logger
+	p.decimals = cfg.output.precision()
+	p.sniffSep = cfg.sepAuto && cfg.filePath == stdinPath
+
+	if cfg.sizingSample > 0 && cfg.filePath != stdinPath && !isDir(cfg.filePath) && !cfg.isTarInput() {
+		hint, estimateErr := estimateFileStations(cfg.filePath, cfg.sizingSample, cfg.opts.separator())
+		if estimateErr != nil {
+			return &inputError{path: cfg.filePath, err: estimateErr}
+		}
+		logger.Info("estimated stations", "phase", "sizing", "stations", hint, "sample_bytes", cfg.sizingSample)
+		p.sizeHint = hint
+		p.stats = make(map[string][4]float64, hint)
+	}
+
+	p.warnings = stderr
+	if cfg.reparseOnError {
+		p.errorContext = stderr
+	}
+
+	if cfg.teePath != "" {
+		teeFile, createErr := os.Create(cfg.teePath)
+		if createErr != nil {
+			return fmt.Errorf("could not create tee file: %w", createErr)
+		}
+		p.tee = bufio.NewWriter(teeFile)
+		defer func() {
+			flushErr := p.tee.Flush()
+			if closeErr := teeFile.Close(); flushErr == nil {
+				flushErr = closeErr
+			}
+			if flushErr != nil && err == nil {
+				err = fmt.Errorf("could not write tee file: %w", flushErr)
+			}
+		}()
+	}
+
+	if cfg.checkpointPath != "" {
+		if cfg.filePath == stdinPath || isDir(cfg.filePath) {
+			return errors.New("-checkpoint requires a single input file")
+		}
+		p.checkpoint = &checkpointer{path: cfg.checkpointPath, every: cfg.checkpointEvery, everyLines: cfg.checkpointEveryLines}
+		if cfg.resume {
+			if err = p.checkpoint.load(p.stats); err != nil {
+				return err
+			}
+		}
+	}
+
+	if cfg.opts.retryOnFault && (cfg.filePath == stdinPath || isDir(cfg.filePath)) {
+		return errors.New("-retry-on-sigbus requires a single input file")
+	}
+	if cfg.opts.firstLast && isDir(cfg.filePath) {
+		return errors.New("-first-last requires a single input file or stdin, directory files are merged in no particular order")
+	}
+
+	var groups *groupWriter
+	if cfg.opts.sortedInput {
+		groups = &groupWriter{w: stdout}
+		p.groups = newGroupAggregator(groups.write)
+	}
+
+	process := func() error {
+		switch {
+		case cfg.isTarInput() && cfg.filePath == stdinPath:
+			return p.processTar(p.withReadTimeout(stdin))
+		case cfg.isTarInput():
+			return p.processTarFile(cfg.filePath)
+		case cfg.filePath == stdinPath:
+			return p.processStdin(stdin)
+		case isDir(cfg.filePath):
+			return p.processDir(cfg.filePath, cfg.fileWorkers)
+		case cfg.workers > 0:
+			var snapshot func(done, total int)
+			if cfg.snapshotInterval > 0 {
+				snapshot = func(done, total int) { fmt.Fprintln(stderr, p.formatSnapshot(done, total)) }
+			}
+			return p.processFileChunks(cfg.filePath, cfg.workers, cfg.workers*chunksPerWorker, cfg.snapshotInterval, snapshot)
+		default:
+			var bar *progressBar
+			if cfg.progressBar && isTerminalWriter(stderr) {
+				bar = newProgressBar(stderr)
+				p.progress = bar.update
+			}
+			if cfg.progressJSON {
+				p.progress = newProgressJSON(stderr, p.stationCount, cfg.progressInterval).update
+			}
+			process := p.processFile
+			if cfg.opts.retryOnFault {
+				process = p.processFileRetrying
+			}
+			err := process(cfg.filePath)
+			if bar != nil {
+				bar.finish()
+			}
+			return err
+		}
+	}
+	if cfg.allocStats {
+		var allocs allocDelta
+		if allocs, err = measureAllocs(process); err == nil {
+			fmt.Fprintln(stderr, allocs)
+		}
+	} else {
+		err = process()
+	}
+	if err != nil {
+		return &inputError{path: cfg.filePath, err: err}
+	}
+	if p.checkpoint != nil {
+		logger.Debug("removing checkpoint", "path", p.checkpoint.path, "saved", p.checkpoint.saved)
+		if err = p.checkpoint.remove(); err != nil {
+			return err
+		}
+	}
+	if cfg.opts.ignoreErrors {
+		fmt.Fprintf(stderr, "skipped %d malformed lines\n", p.skipped)
+	}
+	if cfg.opts.clamp {
+		fmt.Fprintf(stderr, "clamped %d out-of-range temperatures\n", p.clamped)
+	}
+	if p.nans > 0 {
+		fmt.Fprintf(stderr, "ignored %d NaN temperatures\n", p.nans)
+	}
+	if cfg.opts.checkOrder {
+		fmt.Fprintf(stderr, "found %d out-of-order readings\n", p.outOfOrder)
+	}
+	if p.hot != nil {
+		if err = writeHotLines(stderr, p.hot, cfg.opts.hotLines); err != nil {
+			return err
+		}
+	}
+
+	if groups != nil {
+		if err = p.groups.flush(); err != nil {
+			return err
+		}
+		return groups.close()
+	}
+
+	if p.hll != nil {
+		_, err = fmt.Fprintf(stdout, "approx-stations=%d error=±%.2f%%\n", p.hll.estimate(), 100*p.hll.standardError())
+		return err
+	}
+
+	if p.top != nil {
+		p.stats = p.top.stats()
+	}
+
+	if cfg.failOnEmpty && len(p.stats) == 0 {
+		return &inputError{path: cfg.filePath, err: errors.New("no stations were aggregated (-fail-on-empty)")}
+	}
+
+	if cfg.dumpStations {
+		names := slices.Sorted(maps.Keys(p.stats))
+		if cfg.output.collator != nil {
+			cfg.output.collator.SortStrings(names)
+		}
+		for _, name := range names {
+			fmt.Fprintln(stdout, name)
+		}
+		return nil
+	}
+
+	if cfg.appendOutput != "" {
+		// Before -emit-empty adds its placeholders, which aren't readings to accumulate.
+		if err = appendIntermediate(cfg.appendOutput, FromMap(p.stats)); err != nil {
+			return err
+		}
+	}
+
+	if cfg.emitEmpty {
+		for station := range cfg.opts.allowlist {
+			if _, seen := p.stats[station]; !seen {
+				p.stats[station] = [4]float64{} // a zero count marks a station without readings
+			}
+		}
+	}
+
+	if cfg.summary {
+		// Deferred after the output buffer's flush, so it runs first and the footer follows the results.
+		defer func() {
+			if err != nil {
+				return
+			}
+			w := stdout
+			if cfg.summaryTo == summaryToStderr {
+				w = stderr
+			}
+			fmt.Fprintln(w, formatSummary(p.stats, time.Since(start), cfg.output.precision()))
+		}()
+	}
+
+	if cfg.bins > 0 {
+		p.bins = assignBins(p.stats, cfg.bins, cfg.binsMode)
+	}
+
+	if len(cfg.outputs) > 0 {
+		out := cfg.output
+		out.annotate = p.annotate
+		if err = writeOutputTargets(cfg.outputs, p.stats, out); err != nil {
+			return err
+		}
+	}
+
+	if cfg.sortedOutputFile != "" {
+		return writeSortedExternal(cfg.sortedOutputFile, p.stats, cfg.sortRunSize, "")
+	}
+
+	switch cfg.format {
+	case formatText, formatTable:
+	case formatSQLite:
+		return writeSQLite(cfg.outputPath, p.stats)
+	default:
+		fn, _ := lookupFormat(cfg.format) // validated by parseFlags
+		if cfg.outputPath != "" && cfg.output.mmap {
+			return writeFormatFileMmap(cfg.outputPath, fn, FromMap(p.stats))
+		}
+		if cfg.outputPath != "" {
+			return writeFormatFile(cfg.outputPath, fn, FromMap(p.stats))
+		}
+		return fn(stdout, FromMap(p.stats))
+	}
+
+	formatStart := time.Now()
+	out := cfg.output
+	out.annotate = p.annotate
+	var output string
+	switch cfg.format {
+	case formatTable:
+		out.color = useColor(out.color, terminal)
+		output = formatTableOutput(p.stats, out)
+	default:
+		output = formatOutputWith(p.stats, out)
+		if cfg.validateSorted {
+			if err = validateSorted(output); err != nil {
+				return fmt.Errorf("output failed sort validation: %w", err)
+			}
+		}
+		output += "\n"
 	}
+	logger.Info("formatted output", "phase", "format", "duration", time.Since(formatStart))
+	fmt.Fprintln(stdout, output)
+	if p.bins != nil {
+		fmt.Fprintln(stdout, formatBinCounts(p.bins, cfg.bins))
+	}
+	return nil
+}
+
+// -------------------------------------------- Configuration --------------------------------------------
 
-	stats, err := processFile(filePath)
+// config holds everything parsed from the command line.
+type config struct {
+	filePath             string
+	verbosity            int    // 0 = silent, 1 = phase timings (-v), 2 = debug details (-vv)
+	appendOutput         string // intermediate file the run's stats are merged into
+	format               string // output format, one of the format* constants
+	output               outputOptions
+	validateSorted       bool          // check the text output is in 1BRC byte-wise station order
+	detectPrecision      bool          // infer the output precision from the input, see detectPrecision
+	progressBar          bool          // draw a progress bar on stderr when it is a terminal
+	progressJSON         bool          // write progress events to stderr as JSON lines
+	progressInterval     time.Duration // minimum time between two -progress-json events
+	outputPath           string        // destination of file-based output formats
+	outputs              outputTargets // extra -out files the result is written to
+	allowlistPath        string        // file of the stations to aggregate, see options.allowlist
+	blocklistPath        string        // file of the stations to skip, see options.blocklist
+	emitEmpty            bool          // output the allowlisted stations without readings as placeholders
+	checkpointPath       string        // file the progress is checkpointed to, see checkpointer
+	resume               bool          // load the checkpoint and continue from it
+	checkpointEvery      int           // bytes scanned between two checkpoints, unless checkpointEveryLines is set
+	checkpointEveryLines int64         // lines scanned between two checkpoints, 0 to count bytes
+	allocStats           bool          // report the heap allocations of the processing phase
+	dumpStations         bool          // print only the distinct station names
+	failOnEmpty          bool          // fail when no station was aggregated
+	sizingSample         int64         // bytes sampled to pre-size the stats map with -adaptive-sizing, 0 = off
+	sepAuto              bool          // detect opts.sep from the start of the input with autodetectSeparator
+	tarInput             bool          // the input is a tar archive of measurement files, see isTarInput
+	bins                 int           // bucket the stations into this many bins by mean (0 = off)
+	binsMode             string        // -bins strategy, binsModeWidth or binsModeCount
+	workers              int           // goroutines scanning chunks of a single file, 0 for the sequential scan
+	snapshotInterval     time.Duration // minimum time between two -workers snapshots, 0 for none
+	teePath              string        // copy every successfully parsed line to this file
+	sortedOutputFile     string        // write the text output here with an external merge sort
+	sortRunSize          int           // stations per sorted run of the external sort
+	reparseOnError       bool          // print the context of the first parse error to stderr
+	summary              bool          // print a summary footer after the results
+	summaryTo            string        // where the summary goes, summaryToStdout or summaryToStderr
+	offsets              int           // print the boundaries of this many newline-aligned chunks instead of processing
+	fileWorkers          int           // directory input: files processed concurrently (0 = one per CPU)
+	outputBuffer         int           // size of the stdout buffer in bytes
+	opts                 options
+}
+
+// options controls how measurement lines are parsed and aggregated.
+// The zero value parses the plain `station;temperature` format.
+type options struct {
+	byHour            bool                // lines carry a trailing unix timestamp; key by station and hour of day
+	dropPages         bool                // release already-scanned pages of the mapping as the scan advances
+	retryOnFault      bool                // retry a scan aborted by a memory fault (SIGBUS) on a copy of the file
+	maxLineBytes      int                 // streaming path: fail on lines longer than this many bytes (0 = unlimited)
+	maxBytes          int64               // streaming path: stop after this many bytes, finishing the current line (0 = unlimited)
+	readTimeout       time.Duration       // streaming path: fail when no data arrives for this long (0 = wait forever)
+	recursive         bool                // directory input: include the files of subdirectories
+	strict            bool                // directory input with recursive: fail on unreadable files instead of skipping them
+	distinct          bool                // report the number of distinct temperatures per station
+	mode              bool                // report the most common temperature per station
+	percentiles       []float64           // report these percentiles (0 < q <= 100) per station
+	weighted          bool                // lines end with a weight field that weighs the readings in the percentiles
+	checkOrder        bool                // lines end with a unix timestamp; count readings older than their station's latest
+	bySign            bool                // count the negative and non-negative readings per station
+	firstLast         bool                // report the first and last reading of each station in input order
+	cv                bool                // report the variance and coefficient of variation of each station
+	sortedInput       bool                // input is grouped by station in ascending order, aggregate group by group
+	ignoreErrors      bool                // skip malformed lines instead of failing, counting them
+	unitSuffix        bool                // temperatures may end in 'C' or 'F'; Fahrenheit is converted to Celsius
+	kelvin            bool                // unsuffixed temperatures are in Kelvin and converted to Celsius
+	sampleRate        float64             // keep each line with this probability (0 = keep all)
+	seed              uint64              // seed of every random source, see newRand
+	kahan             bool                // use compensated (Neumaier) summation for the per-station sums
+	sep               byte                // field separator, ';' when zero
+	sep2              byte                // fallback separator for lines without sep (0 = none)
+	topK              int                 // keep only the K stations with the highest max (0 = all)
+	hotLines          int                 // report the K most frequent exact lines (0 = off)
+	approxCardinality uint8               // estimate the distinct stations with a HyperLogLog of this precision instead of aggregating (0 = off)
+	assumeASCII       bool                // station names are promised to be ASCII, enabling the byte-oriented fast path
+	quantize          float64             // round each temperature to the nearest multiple of this step (0 = off)
+	limitStations     int                 // keep only the first N distinct stations seen, dropping later ones (0 = all)
+	tenths            bool                // temperatures are integer tenths without a decimal point, e.g. 125 for 12.5
+	clamp             bool                // clamp out-of-range temperatures to the valid range instead of rejecting them
+	maxTempDigits     int                 // reject temperatures with more digits than this (0 = unlimited)
+	tempFirst         bool                // lines are `temp;station`, the temperature precedes the first separator
+	jsonl             bool                // lines are JSON objects like {"station":"Berlin","temp":12.0}, see parseJSONLine
+	utf8Replace       bool                // replace invalid UTF-8 sequences in station names with U+FFFD
+	sanitize          bool                // strip BOMs, CRs and whitespace, and skip blank and comment lines, see sanitizeLine
+	allowlist         map[string]struct{} // only these stations are aggregated (nil = all)
+	blocklist         map[string]struct{} // these stations are never aggregated (nil = none)
+}
+
+// separator returns the configured field separator, defaulting to ';'.
+func (o *options) separator() byte {
+	if o.sep == 0 {
+		return ';'
+	}
+	return o.sep
+}
+
+// needsHistogram reports whether any enabled option is computed from per-station histograms.
+func (o *options) needsHistogram() bool {
+	return o.distinct || o.mode || len(o.percentiles) > 0
+}
+
+// parseFlags parses the command-line arguments into a config.
+// The first positional argument, if any, is the measurements file path; "-" reads stdin
+// and a directory processes every *.txt file inside it.
+func parseFlags(args []string, stderr io.Writer) (*config, error) {
+	cfg := &config{filePath: defaultFilePath}
+
+	fs := flag.NewFlagSet("letsgomeeeeeow", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var verbose, veryVerbose bool
+	fs.BoolVar(&verbose, "v", false, "log phase timings to stderr")
+	fs.BoolVar(&veryVerbose, "vv", false, "log phase timings and debug details to stderr")
+	fs.StringVar(&cfg.format, "format", formatText, "output `format`: text, table, sqlite or a registered one ("+strings.Join(formatNames(), ", ")+")")
+	fs.IntVar(&cfg.offsets, "offsets", 0, "print the byte offsets splitting the file into `K` newline-aligned chunks, one per line, and exit")
+	fs.BoolVar(&cfg.summary, "summary", false, "print a footer with the total records and stations, the global min and max, and the elapsed time")
+	fs.StringVar(&cfg.summaryTo, "summary-to", summaryToStdout, "`stream` the -summary footer goes to: stdout (after the results) or stderr")
+	fs.BoolVar(&cfg.reparseOnError, "reparse-on-error", false, "on a parse error, print the offending line with surrounding lines to stderr")
+	fs.StringVar(&cfg.sortedOutputFile, "sorted-output-to-file", "", "write the text output to the file at `path` with an external merge sort, for huge numbers of stations")
+	fs.IntVar(&cfg.sortRunSize, "sort-run-size", defaultSortRunSize, "stations per sorted run of -sorted-output-to-file")
+	fs.StringVar(&cfg.allowlistPath, "allowlist", "", "only aggregate the stations listed, one per line, in the file at `path`")
+	fs.StringVar(&cfg.blocklistPath, "blocklist", "", "skip the stations listed, one per line, in the file at `path`")
+	fs.BoolVar(&cfg.emitEmpty, "emit-empty", false, "with -allowlist, also output listed stations without readings as `station=-/-/-`")
+	fs.StringVar(&cfg.checkpointPath, "checkpoint", "", "periodically save the progress through the input file to `path`, removed once the run completes")
+	checkpointInterval := fs.String("checkpoint-interval", strconv.Itoa(defaultCheckpointEvery), "save a -checkpoint every `N` bytes scanned, or every N lines with the lines suffix (e.g. 100000lines)")
+	fs.BoolVar(&cfg.resume, "resume", false, "continue from the -checkpoint file of an interrupted run, if there is one")
+	fs.IntVar(&cfg.bins, "bins", 0, "bucket the stations into `N` bins by mean, printing each station's bin and the stations per bin")
+	fs.StringVar(&cfg.binsMode, "bins-mode", binsModeWidth, "-bins `strategy`: width (equal temperature intervals) or count (equal numbers of stations)")
+	fs.DurationVar(&cfg.opts.readTimeout, "read-timeout", 0, "with stdin, fail when no data arrives for `duration`, e.g. from a stalled producer (0 = wait forever)")
+	adaptiveSizing := fs.Bool("adaptive-sizing", false, "pre-size the stats map for the distinct stations found in a sample of the input file, read in a quick pre-pass")
+	fs.Int64Var(&cfg.sizingSample, "sizing-sample", defaultSizingSample, "`bytes` sampled from the start of the file by -adaptive-sizing")
+	fs.BoolVar(&cfg.failOnEmpty, "fail-on-empty", false, "fail instead of printing {} when no station was aggregated, e.g. for a wrong path or an empty file")
+	fs.BoolVar(&cfg.dumpStations, "dump-stations", false, "print only the sorted distinct station names, one per line, e.g. to build an -allowlist")
+	fs.BoolVar(&cfg.allocStats, "alloc-stats", false, "print the heap allocations made while processing the input to stderr")
+	fs.StringVar(&cfg.teePath, "tee", "", "also write every successfully parsed line to the file at `path`")
+	fs.Var(&cfg.outputs, "out", "also write the result in `format=path`, e.g. -out table=out.txt; repeatable for several formats from one run")
+	fs.BoolVar(&cfg.output.mmap, "mmap-output", false, "write -o and -out files (except sqlite) through a growing shared memory mapping instead of buffered io")
+	fs.StringVar(&cfg.outputPath, "o", "", "output `path` for sqlite and registered formats, which otherwise write to stdout")
+	fs.BoolVar(&cfg.output.color, "color", false, "colorize the table output when stdout is a terminal (honors NO_COLOR)")
+	sortKey := fs.String("sort", string(SortByName), "sort stations by `key`: name, mean, min, max or count")
+	fs.BoolVar(&cfg.output.sortDesc, "sort-desc", false, "reverse the sort order")
+	fs.IntVar(&cfg.output.truncate, "truncate-names", 0, "cut station names longer than `N` runes to N with an ellipsis in the text and table output; aggregation uses the full names")
+	collateTag := fs.String("collate", "", "order station names by the rules of the `locale` (e.g. de, sv) instead of byte-wise")
+	fs.BoolVar(&cfg.opts.assumeASCII, "assume-ascii", false, "promise station names are pure ASCII to enable a faster byte-oriented parser (undefined behavior otherwise)")
+	fs.Float64Var(&cfg.opts.quantize, "quantize", 0, "round each temperature to the nearest multiple of `step` before aggregating")
+	fs.IntVar(&cfg.opts.limitStations, "limit-stations", 0, "keep only the first `N` distinct stations, dropping readings of stations that appear later (per file for a directory)")
+	approxCardinality := fs.Bool("approx-cardinality", false, "only estimate the number of distinct stations with a HyperLogLog sketch, in memory bounded by -hll-precision")
+	hllPrecision := fs.Int("hll-precision", defaultHLLPrecision, fmt.Sprintf("index `bits` of the -approx-cardinality sketch (%d-%d): 2^bits bytes, a standard error of 1.04/sqrt(2^bits)", minHLLPrecision, maxHLLPrecision))
+	fs.IntVar(&cfg.opts.hotLines, "profile-hotlines", 0, "report the `K` most frequent exact input lines with their counts on stderr, for deduplication analysis (0 = off)")
+	fs.IntVar(&cfg.opts.topK, "top-k", 0, "keep only the `K` stations with the highest max, in O(K) memory; other metrics are approximate")
+	minOnly := fs.Bool("min-only", false, "text format: print only each station's min")
+	meanOnly := fs.Bool("mean-only", false, "text format: print only each station's mean")
+	maxOnly := fs.Bool("max-only", false, "text format: print only each station's max")
+	fs.StringVar(&cfg.output.fieldSep, "field-sep", "", "text format: `separator` between min, mean and max (default \"/\")")
+	fs.StringVar(&cfg.output.entrySep, "entry-sep", "", "text format: `separator` between stations (default \", \")")
+	fs.BoolVar(&cfg.detectPrecision, "decimal-places-detect", false, "print as many decimal places as the temperatures of the first lines of the input have (default one)")
+	fs.BoolVar(&cfg.validateSorted, "validate-sorted", false, "fail if the text output's stations aren't in 1BRC byte-wise order")
+	fs.BoolVar(&cfg.progressJSON, "progress-json", false, `write single-file progress to stderr as JSON lines, e.g. {"bytes":123,"total":456,"stations":10}`)
+	fs.DurationVar(&cfg.progressInterval, "progress-interval", progressBarInterval, "minimum `duration` between two -progress-json events")
+	fs.BoolVar(&cfg.progressBar, "progress-bar", false, "show a progress bar on stderr for single-file runs when it is a terminal")
+	fs.IntVar(&cfg.workers, "workers", 0, "split a single input file into chunks processed by `N` goroutines, merged as they complete (0 = one sequential scan)")
+	fs.DurationVar(&cfg.snapshotInterval, "snapshot-interval", 0, "with -workers, print the running result to stderr at most every `duration` as chunks complete (0 = off)")
+	fs.BoolVar(&cfg.opts.recursive, "recursive", false, "when given a directory, also process the *.txt and *.txt.gz files of its subdirectories, skipping unreadable ones with a warning")
+	fs.BoolVar(&cfg.opts.strict, "strict", false, "with -recursive, fail on unreadable files and directories instead of skipping them")
+	fs.IntVar(&cfg.fileWorkers, "file-workers", 0, "when given a directory, process at most `N` files concurrently (0 = one per CPU)")
+	fs.IntVar(&cfg.outputBuffer, "output-buffer", defaultOutputBuffer, "size of the stdout write buffer in `bytes`")
+	fs.StringVar(&cfg.appendOutput, "append-output", "", "merge this run's stats into the intermediate file at `path`, creating it if needed")
+	fs.BoolVar(&cfg.opts.byHour, "by-hour", false, "parse 'station;temp;unixSeconds' lines and aggregate per station and hour of day (e.g. Berlin@14)")
+	fs.BoolVar(&cfg.opts.retryOnFault, "retry-on-sigbus", false, "if the mapped file is truncated mid-scan (SIGBUS), process a copy of the file from the start instead of failing")
+	fs.BoolVar(&cfg.opts.dropPages, "drop-pages", false, "release already-processed pages of the memory map to reduce memory footprint")
+	fs.BoolVar(&cfg.opts.sortedInput, "sorted-input", false, "input is grouped by station in ascending order: aggregate without a map and print each station as its group ends (text format only)")
+	fs.BoolVar(&cfg.opts.ignoreErrors, "ignore-errors", false, "skip every malformed line (bad format, number or range) and report how many were skipped")
+	fs.BoolVar(&cfg.opts.utf8Replace, "utf8-replace", false, "replace invalid UTF-8 in station names with U+FFFD, so the output is always valid UTF-8")
+	fs.BoolVar(&cfg.opts.sanitize, "sanitize", false, "clean up messy input: strip byte order marks and CRs, trim whitespace around fields and skip blank and # comment lines")
+	fs.BoolVar(&cfg.opts.tempFirst, "temp-first", false, "parse 'temp;station' lines, taking the field before the first separator as the temperature")
+	fs.BoolVar(&cfg.opts.clamp, "clamp", false, "clamp out-of-range temperatures to [-99.9, 99.9] instead of rejecting them")
+	fs.BoolVar(&cfg.opts.tenths, "tenths", false, "parse temperatures as integer tenths of a degree, e.g. 125 for 12.5")
+	inputUnit := fs.String("input-unit", "C", "`unit` of the temperatures in the input, C or K (Kelvin, converted to Celsius); -unit-suffix readings keep their own")
+	fs.IntVar(&cfg.opts.maxTempDigits, "max-temp-digits", 0, "reject temperatures with more than `N` digits, guarding against absurd values (0 = unlimited)")
+	fs.BoolVar(&cfg.opts.unitSuffix, "unit-suffix", false, "accept temperatures with a trailing 'C' or 'F' unit, converting Fahrenheit to Celsius")
+	fs.Float64Var(&cfg.opts.sampleRate, "sample", 0, "aggregate a random sample keeping each line with probability `p` (0 = all lines)")
+	fs.Uint64Var(&cfg.opts.seed, "seed", 0, "seed for all randomized features, for reproducible runs (default: time-based)")
+	fs.BoolVar(&cfg.opts.kahan, "kahan", false, "use compensated summation for more accurate means on huge inputs")
+	formatIn := fs.String("format-in", inputText, "input `format`: text (station;temperature lines), jsonl (one {\"station\":...,\"temp\":...} object per line) or tar (an archive of *.txt and *.txt.gz text files, also selected by a .tar extension)")
+	sep := fs.String("sep", ";", "field `separator` between station and temperature; with ' ' lines split on their last space, with auto it is detected from the first lines")
+	sep2 := fs.String("sep2", "", "fallback `separator` for lines that don't contain -sep")
+	fs.BoolVar(&cfg.opts.distinct, "distinct", false, "append the number of distinct temperatures reported by each station")
+	fs.BoolVar(&cfg.opts.cv, "cv", false, "append the variance and coefficient of variation (stddev / |mean|) of each station")
+	fs.BoolVar(&cfg.opts.firstLast, "first-last", false, "append the first and last temperature of each station in input order")
+	fs.BoolVar(&cfg.opts.bySign, "by-sign", false, "append how many readings of each station were below zero and at or above zero")
+	percentiles := fs.String("percentiles", "", "append the given comma-separated percentiles of each station, e.g. 50,90,99 (nearest rank, at one decimal)")
+	fs.BoolVar(&cfg.opts.weighted, "weighted", false, "lines are `station;temperature;weight`, and -percentiles are computed over the weighted readings")
+	fs.BoolVar(&cfg.opts.checkOrder, "check-order", false, "lines are `station;temperature;unixSeconds`; report how many readings arrived out of chronological order for their station")
+	fs.BoolVar(&cfg.opts.mode, "mode", false, "append the most common temperature of each station, the lowest one on a tie")
+	fs.IntVar(&cfg.opts.maxLineBytes, "max-line-bytes", 0, "when reading stdin, fail on lines longer than `N` bytes (0 = unlimited)")
+	fs.Int64Var(&cfg.opts.maxBytes, "max-bytes", 0, "when reading stdin, stop after `N` bytes, completing the current line (0 = unlimited)")
+
+	err := fs.Parse(args)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	switch {
+	case veryVerbose:
+		cfg.verbosity = 2
+	case verbose:
+		cfg.verbosity = 1
+	}
+	seedSet := false
+	fs.Visit(func(f *flag.Flag) { seedSet = seedSet || f.Name == "seed" })
+	if !seedSet {
+		cfg.opts.seed = timeSeed()
+	}
+	if cfg.opts.sampleRate < 0 || cfg.opts.sampleRate > 1 {
+		return nil, fmt.Errorf("sample rate must be between 0 and 1, got %v", cfg.opts.sampleRate)
+	}
+	if *sep == sepAuto {
+		cfg.sepAuto = true // detected by run once the input is open, ';' until then
+	} else if cfg.opts.sep, err = parseSeparator("sep", *sep); err != nil {
+		return nil, err
+	}
+	if *sep2 != "" {
+		if cfg.opts.sep2, err = parseSeparator("sep2", *sep2); err != nil {
+			return nil, err
+		}
+	}
+	switch cfg.format {
+	case formatText, formatTable:
+	case formatSQLite, formatMsgpack:
+		if cfg.outputPath == "" {
+			return nil, fmt.Errorf("-format %s requires -o", cfg.format)
+		}
+	default:
+		if _, ok := lookupFormat(cfg.format); !ok {
+			return nil, fmt.Errorf("unknown output format %q", cfg.format)
+		}
+	}
+	if cfg.output.mmap && len(cfg.outputs) == 0 && (cfg.outputPath == "" || cfg.format == formatText || cfg.format == formatTable || cfg.format == formatSQLite) {
+		return nil, errors.New("-mmap-output requires -out, or -o with a registered format such as msgpack")
+	}
+	if cfg.output.sortKey, err = parseSortKey(*sortKey); err != nil {
+		return nil, err
+	}
+	if *collateTag != "" {
+		if cfg.validateSorted || cfg.opts.sortedInput || cfg.sortedOutputFile != "" {
+			return nil, errors.New("-collate can't be combined with -validate-sorted, -sorted-input or -sorted-output-to-file, which need byte-wise order")
+		}
+		if cfg.output.collator, err = newCollator(*collateTag); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.summaryTo != summaryToStdout && cfg.summaryTo != summaryToStderr {
+		return nil, fmt.Errorf("-summary-to must be %s or %s, got %q", summaryToStdout, summaryToStderr, cfg.summaryTo)
+	}
+	if cfg.emitEmpty && (cfg.allowlistPath == "" || cfg.format != formatText || cfg.sortedOutputFile != "") {
+		return nil, errors.New("-emit-empty requires -allowlist and the text format, without -sorted-output-to-file")
+	}
+	if cfg.checkpointEvery, cfg.checkpointEveryLines, err = parseCheckpointInterval(*checkpointInterval); err != nil {
+		return nil, err
+	}
+	if cfg.dumpStations && (cfg.format != formatText || cfg.opts.sortedInput || cfg.summary || cfg.sortedOutputFile != "" || len(cfg.outputs) > 0 || cfg.emitEmpty) {
+		return nil, errors.New("-dump-stations can't be combined with -format, -sorted-input, -summary, -sorted-output-to-file, -out or -emit-empty")
+	}
+	if *adaptiveSizing {
+		if cfg.sizingSample < 1 {
+			return nil, errors.New("-sizing-sample must be at least 1")
+		}
+	} else {
+		cfg.sizingSample = 0 // no pre-pass
+	}
+	if cfg.opts.strict && !cfg.opts.recursive {
+		return nil, errors.New("-strict requires -recursive")
+	}
+	if cfg.opts.readTimeout < 0 {
+		return nil, errors.New("-read-timeout must not be negative")
+	}
+	if cfg.bins < 0 {
+		return nil, errors.New("-bins must not be negative")
+	}
+	if cfg.binsMode != binsModeWidth && cfg.binsMode != binsModeCount {
+		return nil, fmt.Errorf("-bins-mode must be %s or %s, got %q", binsModeWidth, binsModeCount, cfg.binsMode)
+	}
+	if cfg.bins > 0 && ((cfg.format != formatText && cfg.format != formatTable) || cfg.opts.sortedInput || cfg.sortedOutputFile != "" || cfg.dumpStations) {
+		return nil, errors.New("-bins requires the text or table format, without -sorted-input, -sorted-output-to-file or -dump-stations")
+	}
+	if cfg.failOnEmpty && cfg.opts.sortedInput {
+		return nil, errors.New("-fail-on-empty can't be combined with -sorted-input")
+	}
+	if len(cfg.outputs) > 0 && cfg.opts.sortedInput {
+		return nil, errors.New("-out can't be combined with -sorted-input")
+	}
+	switch *inputUnit {
+	case "C":
+	case "K":
+		cfg.opts.kelvin = true
+	default:
+		return nil, fmt.Errorf("-input-unit must be C or K, got %q", *inputUnit)
+	}
+	switch *formatIn {
+	case inputText:
+	case inputTar:
+		cfg.tarInput = true
+	case inputJSONL:
+		if cfg.sepAuto || cfg.opts.byHour || cfg.opts.tempFirst || cfg.opts.tenths || cfg.opts.unitSuffix || cfg.opts.kelvin ||
+			cfg.opts.sep != ';' || cfg.opts.sep2 != 0 || cfg.opts.maxTempDigits > 0 || cfg.detectPrecision {
+			return nil, errors.New("-format-in jsonl can't be combined with -by-hour, -temp-first, -tenths, -unit-suffix, -input-unit, -sep, -sep2, -max-temp-digits or -decimal-places-detect")
+		}
+		cfg.opts.jsonl = true
+	default:
+		return nil, fmt.Errorf("-format-in must be %s, %s or %s, got %q", inputText, inputJSONL, inputTar, *formatIn)
+	}
+	if cfg.output.truncate < 0 {
+		return nil, errors.New("-truncate-names must not be negative")
+	}
+	if cfg.output.truncate > 0 && (cfg.validateSorted || cfg.opts.sortedInput || cfg.sortedOutputFile != "") {
+		return nil, errors.New("-truncate-names can't be combined with -validate-sorted, -sorted-input or -sorted-output-to-file")
+	}
+	if *percentiles != "" {
+		if cfg.opts.percentiles, err = parsePercentiles(*percentiles); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.opts.weighted && (len(cfg.opts.percentiles) == 0 || cfg.opts.jsonl || cfg.opts.byHour || cfg.opts.tempFirst) {
+		return nil, errors.New("-weighted requires -percentiles and can't be combined with -format-in jsonl, -by-hour or -temp-first")
+	}
+	if cfg.opts.checkOrder && (cfg.opts.jsonl || cfg.opts.byHour || cfg.opts.weighted || cfg.workers > 0 || cfg.checkpointPath != "") {
+		return nil, errors.New("-check-order can't be combined with -format-in jsonl, -by-hour, -weighted, -workers or -checkpoint")
+	}
+	if cfg.progressJSON && cfg.progressBar {
+		return nil, errors.New("-progress-json can't be combined with -progress-bar")
+	}
+	if cfg.progressInterval < 0 {
+		return nil, errors.New("-progress-interval must not be negative")
+	}
+	if cfg.resume && cfg.checkpointPath == "" {
+		return nil, errors.New("-resume requires -checkpoint")
+	}
+	if cfg.checkpointPath != "" && (cfg.opts.sortedInput || cfg.opts.topK > 0 || cfg.opts.needsHistogram() || cfg.opts.bySign || cfg.opts.kahan || cfg.opts.sampleRate > 0) {
+		return nil, errors.New("-checkpoint can't be combined with -sorted-input, -top-k, -distinct, -mode, -by-sign, -kahan or -sample")
+	}
+	if cfg.output.fieldSep != "" || cfg.output.entrySep != "" {
+		if cfg.format != formatText || cfg.opts.sortedInput || cfg.sortedOutputFile != "" || cfg.validateSorted {
+			return nil, errors.New("-field-sep and -entry-sep only apply to the text format, without -sorted-input, -sorted-output-to-file or -validate-sorted")
+		}
+		if cfg.output.fieldSep != "" {
+			if err = validateOutputSeparator("field-sep", cfg.output.fieldSep); err != nil {
+				return nil, err
+			}
+		}
+		if cfg.output.entrySep != "" {
+			if err = validateOutputSeparator("entry-sep", cfg.output.entrySep); err != nil {
+				return nil, err
+			}
+		}
+		if cfg.output.fieldSeparator() == cfg.output.entrySeparator() {
+			return nil, errors.New("-field-sep and -entry-sep must differ")
+		}
+	}
+	if cfg.detectPrecision && (cfg.opts.sortedInput || cfg.sortedOutputFile != "") {
+		return nil, errors.New("-decimal-places-detect can't be combined with -sorted-input or -sorted-output-to-file")
+	}
+	if cfg.workers < 0 {
+		return nil, errors.New("-workers must not be negative")
+	}
+	if cfg.snapshotInterval > 0 && cfg.workers == 0 {
+		return nil, errors.New("-snapshot-interval requires -workers")
+	}
+	if cfg.workers > 0 && cfg.checkpointPath != "" {
+		return nil, errors.New("-workers can't be combined with -checkpoint")
+	}
+	if cfg.workers > 0 && cfg.opts.limitStations > 0 {
+		// Every chunk would keep its own first N stations, not the first N of the file.
+		return nil, errors.New("-workers can't be combined with -limit-stations")
+	}
+	if cfg.sortRunSize < 1 {
+		return nil, errors.New("-sort-run-size must be at least 1")
+	}
+	if cfg.opts.tempFirst && cfg.opts.byHour {
+		return nil, errors.New("-temp-first can't be combined with -by-hour")
+	}
+	if cfg.opts.maxTempDigits < 0 {
+		return nil, errors.New("-max-temp-digits must not be negative")
+	}
+	if cfg.opts.quantize < 0 {
+		return nil, errors.New("-quantize must not be negative")
+	}
+	if cfg.opts.limitStations < 0 {
+		return nil, errors.New("-limit-stations must not be negative")
+	}
+	if *approxCardinality {
+		if *hllPrecision < minHLLPrecision || *hllPrecision > maxHLLPrecision {
+			return nil, fmt.Errorf("-hll-precision must be between %d and %d", minHLLPrecision, maxHLLPrecision)
+		}
+		if cfg.format != formatText || cfg.opts.sortedInput || cfg.opts.topK > 0 || cfg.opts.needsHistogram() || cfg.opts.bySign || cfg.opts.kahan ||
+			cfg.checkpointPath != "" || cfg.summary || len(cfg.outputs) > 0 || cfg.dumpStations || cfg.failOnEmpty || cfg.emitEmpty ||
+			cfg.sortedOutputFile != "" || cfg.appendOutput != "" {
+			return nil, errors.New("-approx-cardinality only prints the estimate and can't be combined with options producing per-station output")
+		}
+		cfg.opts.approxCardinality = uint8(*hllPrecision)
+	}
+	if cfg.opts.hotLines < 0 {
+		return nil, errors.New("-profile-hotlines must not be negative")
+	}
+	if cfg.opts.topK < 0 {
+		return nil, errors.New("-top-k must not be negative")
+	}
+	if cfg.opts.retryOnFault && (cfg.workers > 0 || cfg.checkpointPath != "" || cfg.teePath != "" || cfg.opts.sortedInput) {
+		// A retry starts over, so it can't undo the lines an aborted scan already saved, teed or emitted.
+		return nil, errors.New("-retry-on-sigbus can't be combined with -workers, -checkpoint, -tee or -sorted-input")
+	}
+	if cfg.opts.cv && (cfg.checkpointPath != "" || cfg.opts.topK > 0 || cfg.opts.sortedInput) {
+		return nil, errors.New("-cv can't be combined with -checkpoint, -top-k or -sorted-input")
+	}
+	if cfg.opts.firstLast && (cfg.workers > 0 || cfg.checkpointPath != "" || cfg.opts.topK > 0 || cfg.opts.sortedInput) {
+		// Chunks are merged in completion order and a resumed run has lost the first readings,
+		// so only a single in-order pass sees the true first and last readings.
+		return nil, errors.New("-first-last can't be combined with -workers, -checkpoint, -top-k or -sorted-input")
+	}
+	if cfg.opts.topK > 0 && (cfg.opts.sortedInput || cfg.opts.needsHistogram() || cfg.opts.bySign || cfg.opts.kahan) {
+		return nil, errors.New("-top-k can't be combined with -sorted-input, -distinct, -mode, -by-sign or -kahan")
+	}
+	for only, set := range map[SortKey]bool{SortByMin: *minOnly, SortByMean: *meanOnly, SortByMax: *maxOnly} {
+		if !set {
+			continue
+		}
+		if cfg.output.only != "" {
+			return nil, errors.New("-min-only, -mean-only and -max-only are mutually exclusive")
+		}
+		cfg.output.only = only
+	}
+	if cfg.output.only != "" && (cfg.format != formatText || slices.ContainsFunc(cfg.outputs, func(target outputTarget) bool { return target.format != formatText })) {
+		return nil, errors.New("-min-only, -mean-only and -max-only only apply to the text format, including -out targets")
+	}
+	if cfg.opts.sortedInput && (cfg.format != formatText || cfg.output.sortKey != SortByName || cfg.output.sortDesc || cfg.output.only != "" ||
+		cfg.opts.needsHistogram() || cfg.opts.bySign || cfg.opts.kahan || cfg.opts.limitStations > 0 ||
+		cfg.summary || cfg.appendOutput != "" || cfg.emitEmpty || cfg.validateSorted || cfg.sortedOutputFile != "") {
+		// Each group is printed in the text format as soon as it ends, without the per-station
+		// state these options need, and the run ends with the last group.
+		return nil, errors.New("-sorted-input can't be combined with -format, -sort, -sort-desc, -min-only, -mean-only, -max-only, " +
+			"-distinct, -mode, -percentiles, -by-sign, -kahan, -limit-stations, -summary, -append-output, -emit-empty, -validate-sorted or -sorted-output-to-file")
+	}
+	if cfg.sortedOutputFile != "" && (cfg.format != formatText || cfg.output.sortKey != SortByName || cfg.output.sortDesc || cfg.output.only != "" ||
+		cfg.opts.needsHistogram() || cfg.opts.bySign || cfg.opts.firstLast || cfg.opts.cv || cfg.validateSorted) {
+		// The sorted runs hold only the [min, sum, count, max] tuples and are merged into the
+		// name-ordered text format.
+		return nil, errors.New("-sorted-output-to-file can't be combined with -format, -sort, -sort-desc, -min-only, -mean-only, -max-only, " +
+			"-distinct, -mode, -percentiles, -by-sign, -first-last, -cv or -validate-sorted")
+	}
+	if fs.NArg() > 0 {
+		cfg.filePath = fs.Arg(0)
 	}
 
-	output := formatOutput(stats)
-	fmt.Println(output)
-	fmt.Println()
+	return cfg, nil
+}
+
+// parseSeparator validates that a separator flag value is a single byte.
+func parseSeparator(name, value string) (byte, error) {
+	if len(value) != 1 {
+		return 0, fmt.Errorf("-%s must be a single byte, got %q", name, value)
+	}
+	return value[0], nil
+}
+
+// newLogger creates the stderr logger for the given verbosity.
+// With verbosity 0 every record is discarded before any formatting happens.
+func newLogger(stderr io.Writer, verbosity int) *slog.Logger {
+	switch verbosity {
+	case 0:
+		return slog.New(slog.DiscardHandler)
+	case 1:
+		return slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	default:
+		return slog.New(slog.NewTextHandler(stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
+}
+
+// -------------------------------------------- Processor --------------------------------------------
+
+// processor parses measurement lines according to its options and aggregates them
+// into per-station [min, sum, count, max] tuples.
+type processor struct {
+	opts         options
+	stats        map[string][4]float64
+	hists        map[string]*histogram         // per-station histograms, nil unless an option needs them
+	weighted     map[string]*weightedHistogram // per-station weighted histograms, nil unless opts.weighted
+	sizeHint     int                           // expected number of stations the maps are pre-sized for, see estimateStations
+	sums         map[string]*compensatedSum    // per-station compensated sums, nil unless opts.kahan is set
+	signs        map[string]*[2]int64          // per-station [negative, non-negative] reading counts, nil unless opts.bySign is set
+	firstLast    map[string]*[2]float64        // per-station [first, last] readings, nil unless opts.firstLast is set
+	squares      map[string]float64            // per-station sums of squared readings, nil unless opts.cv is set
+	groups       *groupAggregator              // replaces stats when opts.sortedInput is set
+	top          *topK                         // replaces stats when opts.topK is set
+	hll          *hyperLogLog                  // replaces stats when opts.approxCardinality is set
+	hot          *hotLines                     // counts of the exact lines, nil unless opts.hotLines is set
+	bins         map[string]int                // -bins index of each station, set after aggregation, may be nil
+	asciiStats   map[string]*[4]float64        // stats collected by the -assume-ascii fast path, see flushASCII
+	tee          *bufio.Writer                 // receives every successfully parsed line, may be nil
+	errorContext io.Writer                     // receives the surrounding lines of the first line that fails, may be nil
+	warnings     io.Writer                     // where skipped inputs are reported, may be nil
+	lines        int64                         // non-empty lines seen, including skipped ones
+	skipped      int64                         // malformed lines skipped with opts.ignoreErrors
+	clamped      int64                         // out-of-range temperatures clamped with opts.clamp
+	nans         int64                         // NaN temperatures, ignored rather than aggregated
+	lastSeen     map[string]int64              // latest timestamp per station, nil unless opts.checkOrder is set
+	outOfOrder   int64                         // readings older than their station's latest timestamp with opts.checkOrder
+	rng          *rand.Rand                    // random source, nil unless an option needs one
+	logger       *slog.Logger
+	decimals     int                                 // digits after the decimal point of the temperatures annotate prints
+	dropWindow   int                                 // bytes scanned between page drops when opts.dropPages is set
+	progress     func(done, total int64)             // called with the bytes scanned so far, may be nil
+	mapper       func(file *os.File) ([]byte, error) // maps the file for processFile, mmapFile unless a test injects one
+	checkpoint   *checkpointer                       // saves the progress of processFile, may be nil
+	sniffSep     bool                                // detect opts.sep from the start of the input in processStdin
+}
+
+// defaultDropWindow is how many bytes are scanned between two MADV_DONTNEED calls.
+const defaultDropWindow = 64 << 20
+
+// newProcessor creates a processor with an empty stats map.
+func newProcessor(opts options) *processor {
+	p := &processor{
+		opts:       opts,
+		stats:      make(map[string][4]float64),
+		logger:     slog.New(slog.DiscardHandler),
+		decimals:   1,
+		dropWindow: defaultDropWindow,
+		mapper:     mmapFile,
+	}
+	if opts.weighted {
+		p.weighted = make(map[string]*weightedHistogram)
+	}
+	if opts.needsHistogram() {
+		p.hists = make(map[string]*histogram)
+	}
+	if opts.checkOrder {
+		p.lastSeen = make(map[string]int64)
+	}
+	if opts.kahan {
+		p.sums = make(map[string]*compensatedSum)
+	}
+	if opts.bySign {
+		p.signs = make(map[string]*[2]int64)
+	}
+	if opts.firstLast {
+		p.firstLast = make(map[string]*[2]float64)
+	}
+	if opts.cv {
+		p.squares = make(map[string]float64)
+	}
+	if opts.sampleRate > 0 {
+		p.rng = newRand(opts.seed)
+	}
+	if opts.topK > 0 {
+		p.top = newTopK(opts.topK)
+	}
+	if opts.approxCardinality > 0 {
+		p.hll = newHyperLogLog(opts.approxCardinality)
+	}
+	if opts.hotLines > 0 {
+		p.hot = newHotLines()
+	}
+	return p
+}
+
+// stationCount returns the number of distinct stations aggregated so far, including those
+// still held by the ASCII fast path.
+func (p *processor) stationCount() int {
+	return len(p.stats) + len(p.asciiStats)
+}
+
+// merge folds the results of other, which must have been created with the same options,
+// into p.
+func (p *processor) merge(other *processor) {
+	for station, tup := range other.stats {
+		existing, exists := p.stats[station]
+		if !exists {
+			p.stats[station] = tup
+			continue
+		}
+		p.stats[station] = [4]float64{
+			math.Min(existing[0], tup[0]), // min
+			existing[1] + tup[1],          // sum
+			existing[2] + tup[2],          // count
+			math.Max(existing[3], tup[3]), // max
+		}
+	}
+
+	for station, s := range other.sums {
+		existing, exists := p.sums[station]
+		if !exists {
+			p.sums[station] = s
+			continue
+		}
+		existing.add(s.sum)
+		existing.add(s.c)
+		tup := p.stats[station]
+		tup[1] = existing.value()
+		p.stats[station] = tup
+	}
+
+	for station, h := range other.hists {
+		if existing, exists := p.hists[station]; exists {
+			existing.merge(h)
+		} else {
+			p.hists[station] = h
+		}
+	}
+
+	for station, h := range other.weighted {
+		if existing, exists := p.weighted[station]; exists {
+			existing.merge(h)
+		} else {
+			p.weighted[station] = h
+		}
+	}
+
+	for station, seconds := range other.lastSeen {
+		if existing, exists := p.lastSeen[station]; !exists || seconds > existing {
+			p.lastSeen[station] = seconds
+		}
+	}
+
+	for station, counts := range other.signs {
+		if existing, exists := p.signs[station]; exists {
+			existing[0] += counts[0]
+			existing[1] += counts[1]
+		} else {
+			p.signs[station] = counts
+		}
+	}
+
+	for station, sum := range other.squares {
+		p.squares[station] += sum
+	}
+
+	// other is assumed to follow p in the input: p keeps its first readings, other's last win.
+	for station, values := range other.firstLast {
+		if existing, exists := p.firstLast[station]; exists {
+			existing[1] = values[1]
+		} else {
+			p.firstLast[station] = values
+		}
+	}
+
+	if p.top != nil {
+		for _, e := range other.top.entries {
+			p.top.observe(e.station, e.tup)
+		}
+	}
+	if p.hll != nil {
+		p.hll.merge(other.hll)
+	}
+	if p.hot != nil {
+		p.hot.merge(other.hot)
+	}
+
+	p.lines += other.lines
+	p.skipped += other.skipped
+	p.clamped += other.clamped
+	p.nans += other.nans
+	p.outOfOrder += other.outOfOrder
+}
+
+// annotate returns the extra per-station fields enabled by p.opts, appended to the
+// station's min/mean/max in the output.
+func (p *processor) annotate(station string) string {
+	if p.stats[station][2] == 0 {
+		return "" // a -emit-empty placeholder has nothing to annotate
+	}
+	var extra strings.Builder
+	if p.opts.distinct {
+		fmt.Fprintf(&extra, " distinct=%d", p.hists[station].distinct())
+	}
+	if p.opts.mode {
+		fmt.Fprintf(&extra, " mode=%.*f", p.decimals, p.hists[station].mode())
+	}
+	for _, q := range p.opts.percentiles {
+		value := p.hists[station].percentile(q)
+		if p.weighted != nil {
+			value = p.weighted[station].percentile(q)
+		}
+		fmt.Fprintf(&extra, " p%s=%.*f", strconv.FormatFloat(q, 'f', -1, 64), p.decimals, value)
+	}
+	if p.opts.bySign {
+		counts := p.signs[station]
+		fmt.Fprintf(&extra, " negative=%d non-negative=%d", counts[0], counts[1])
+	}
+	if p.opts.firstLast {
+		values := p.firstLast[station]
+		fmt.Fprintf(&extra, " first=%.*f last=%.*f", p.decimals, values[0], p.decimals, values[1])
+	}
+	if p.opts.cv {
+		extra.WriteString(formatVariation(p.stats[station], p.squares[station]))
+	}
+	if p.bins != nil {
+		fmt.Fprintf(&extra, " bin=%d", p.bins[station])
+	}
+	return extra.String()
 }
 
 // -------------------------------------------- Helper Functions --------------------------------------------
 
 // processFile reads a file and returns the statistics for all stations.
 func processFile(filePath string) (map[string][4]float64, error) {
+	p := newProcessor(options{})
+	if err := p.processFile(filePath); err != nil {
+		return nil, err
+	}
+	return p.stats, nil
+}
+
+// ProcessFileInto aggregates the file at path into dst, a map of [min, sum, count, max]
+// tuples, merging with any stations it already holds instead of allocating a new map. This
+// lets callers reuse one map across calls and accumulate several files without a merge step.
+// On error dst may hold part of the file.
+func ProcessFileInto(path string, dst map[string][4]float64) error {
+	p := newProcessor(options{})
+	p.stats = dst
+	return p.processFile(path)
+}
+
+// processFile reads a file and aggregates every line into p.stats.
+func (p *processor) processFile(filePath string) (err error) {
+	phaseStart := time.Now()
 	file, err := os.Open(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("could not open file: %w", err)
+		return fmt.Errorf("could not open file: %w", err)
 	}
+	p.logger.Info("opened file", "phase", "open", "path", filePath, "duration", time.Since(phaseStart))
 	defer func(file *os.File) {
-		if err = file.Close(); err != nil {
-			panic(err)
+		if closeErr := file.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("could not close file: %w", closeErr)
 		}
 	}(file)
 
-	stats := make(map[string][4]float64)
+	phaseStart = time.Now()
+	data, mapped, err := p.loadFile(file)
+	if err != nil {
+		return err
+	}
+	if mapped {
+		defer func() {
+			if unmapErr := syscall.Munmap(data); unmapErr != nil && err == nil {
+				err = fmt.Errorf("could not unmap memory: %w", unmapErr)
+			}
+		}()
+		// Recover from the fault of reading past a truncation during the scan.
+		defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+		defer recoverFault(&err)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	p.logger.Info("mapped file", "phase", "mmap", "mapped", mapped, "duration", time.Since(phaseStart))
+	p.logger.Debug("mapping details", "bytes", len(data), "page_size", os.Getpagesize())
+
+	phaseStart = time.Now()
+	start := 0
+	if p.checkpoint != nil {
+		if p.checkpoint.offset > len(data) {
+			return fmt.Errorf("checkpoint offset %d is past the end of the file (%d bytes)", p.checkpoint.offset, len(data))
+		}
+		start = p.checkpoint.offset
+	}
+	lf, crlf, err := p.scanData(data, start, mapped && p.opts.dropPages)
+	if err != nil {
+		return err
+	}
+	if mapped {
+		if err = checkFileSize(file, int64(len(data))); err != nil {
+			return err
+		}
+	}
+	if lf > 0 && crlf > 0 {
+		p.logger.Warn("mixed line endings", "path", filePath, "lf", lf, "crlf", crlf)
+	}
+	p.logger.Info("scanned file", "phase", "scan", "duration", time.Since(phaseStart))
+	p.logger.Debug("scan details", "stations", len(p.stats))
+
+	return nil
+}
+
+// loadFile memory-maps the file with p.mapper. An empty file can't be memory-mapped, and
+// files on special filesystems such as /proc report a size of zero or refuse to be mapped:
+// those are read into memory instead. It reports whether data is a mapping, which the
+// caller must unmap.
+func (p *processor) loadFile(file *os.File) (data []byte, mapped bool, err error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, false, fmt.Errorf("could not get file info: %w", err)
+	}
 
 	//note: We know we're going to read the whole file, so buffered reading isn't optimal.
 	// Memory mapping tells the kernel to make the file accessible as memory.
-	mmap := mmapFile(file)
-	defer func() {
-		if err = syscall.Munmap(mmap); err != nil {
-			panic(fmt.Sprintf("could not unmap memory: %v", err))
+	if info.Size() > 0 {
+		if data, err = p.mapper(file); err == nil {
+			// The file may change between the two Stat calls or during the scan: the sizes
+			// before and after are compared, see also checkFileSize.
+			if int64(len(data)) != info.Size() {
+				_ = syscall.Munmap(data)
+				return nil, false, fmt.Errorf("file size changed while processing: was %d bytes, mapped %d", info.Size(), len(data))
+			}
+			return data, true, nil
 		}
-	}()
+		p.logger.Warn("falling back to reading the file", "path", file.Name(), "error", err)
+	}
+	if data, err = io.ReadAll(file); err != nil {
+		return nil, false, fmt.Errorf("could not read file: %w", err)
+	}
+	return data, false, nil
+}
 
-	start := 0
-	for i, b := range mmap {
-		if b == '\n' {
-			if i > start {
-				line := string(mmap[start:i]) // Extract the substring from where we started to just before the newline
-				if err = processLine(line, stats); err != nil {
-					return nil, err
+// scanData aggregates the newline-delimited lines of data from offset start on, counting the
+// LF and CRLF line endings seen, to detect files concatenated from different sources. With
+// drop set, data is a mapping whose already-scanned pages are released as the scan advances.
+func (p *processor) scanData(data []byte, start int, drop bool) (lf, crlf int, err error) {
+	dropped, reported := start-start%os.Getpagesize(), start // madvise needs a page-aligned start
+	fast := p.opts.asciiFastPath() && p.tee == nil && p.checkpoint == nil
+	if fast {
+		p.asciiStats = make(map[string]*[4]float64, p.sizeHint)
+		defer p.flushASCII()
+	}
+	for i := start; i < len(data); i++ {
+		if data[i] == '\n' {
+			end := i
+			if i > start && data[i-1] == '\r' {
+				end = i - 1
+				crlf++
+			} else {
+				lf++
+			}
+			if end > start {
+				if fast {
+					err = p.processASCIILine(data[start:end])
+				} else {
+					line := string(data[start:end]) // Extract the substring from where we started to just before the line ending
+					err = p.processLine(line)
+				}
+				if err != nil {
+					p.reportErrorContext(data, start)
+					return lf, crlf, err
 				}
 			}
 			start = i + 1 // Move start position to after the newline for next iteration
+
+			if p.checkpoint != nil && p.checkpoint.due(start, p.lines) {
+				if err = p.checkpoint.save(start, p.stats); err != nil {
+					return lf, crlf, err
+				}
+				p.checkpoint.lines = p.lines
+			}
+
+			if drop && start-dropped >= p.dropWindow {
+				if dropped, err = dropPages(data, dropped, start); err != nil {
+					return lf, crlf, err
+				}
+			}
+			if p.progress != nil && start-reported >= progressStep {
+				p.progress(int64(start), int64(len(data)))
+				reported = start
+			}
 		}
 	}
 	// Process the last line if it doesn't end with newline
-	if start < len(mmap) {
-		line := string(mmap[start:])
+	if start < len(data) {
+		line := strings.TrimSuffix(string(data[start:]), "\r")
 		if len(line) > 0 {
-			if err = processLine(line, stats); err != nil {
-				return nil, err
+			if err = p.processLine(line); err != nil {
+				p.reportErrorContext(data, start)
+				return lf, crlf, err
 			}
 		}
 	}
-
-	return stats, nil
+	if p.progress != nil {
+		p.progress(int64(len(data)), int64(len(data)))
+	}
+	return lf, crlf, nil
 }
 
 // mmapFile Memory-map a file into read-only byte slice using `syscall.Mmap`.
@@ -99,16 +1291,16 @@ func processFile(filePath string) (map[string][4]float64, error) {
 //   - The mapping is automatically unmapped when the slice goes out of scope
 //     (via the OS when process exits, but Rust doesn't track this lifetime)
 //
-// # Panics
+// # Errors
 // - If file metadata cannot be read
 // - If `mmap` system call fails (e.g., insufficient memory, invalid file descriptor)
 //
 // A byte slice (`[]byte`) referencing the memory-mapped file contents.
-func mmapFile(file *os.File) []byte {
+func mmapFile(file *os.File) ([]byte, error) {
 	// Get file info for memory mapping
 	info, err := file.Stat()
 	if err != nil {
-		panic(fmt.Sprintf("could not get file info: %v", err))
+		return nil, fmt.Errorf("could not get file info: %w", err)
 	}
 	fileSize := int(info.Size())
 
@@ -122,7 +1314,7 @@ func mmapFile(file *os.File) []byte {
 		syscall.MAP_SHARED, // Changes visible to other processes & persisted to file
 	)
 	if err != nil {
-		panic(fmt.Sprintf("could not memory map file: %v", err))
+		return nil, fmt.Errorf("could not memory map file: %w", err)
 	}
 
 	//note: advise os on how this memory map will be accessed.
@@ -130,30 +1322,270 @@ func mmapFile(file *os.File) []byte {
 	// offset, we're going to be reading in a sequential order,
 	// so feel free to read ahead more (huge ass more) in advance.
 	if err = syscall.Madvise(data, syscall.MADV_SEQUENTIAL); err != nil {
-		panic(fmt.Sprintf("could not advise os on how this memory map will be accessed: %v", err))
+		_ = syscall.Munmap(data)
+		return nil, fmt.Errorf("could not advise os on how this memory map will be accessed: %w", err)
 	}
 
-	return data
+	return data, nil
+}
+
+// dropPages tells the kernel that mmap[from:to] has been consumed and its pages can be
+// released with MADV_DONTNEED. `to` is rounded down to a page boundary so the page holding
+// the line currently being scanned is kept. It returns the new drop watermark.
+//
+// note: The mapping is read-only and file-backed, so a dropped page that is touched again
+// is simply faulted back in from the page cache or disk; correctness doesn't depend on it.
+func dropPages(mmap []byte, from, to int) (int, error) {
+	to -= to % os.Getpagesize()
+	if to <= from {
+		return from, nil
+	}
+
+	if err := syscall.Madvise(mmap[from:to], syscall.MADV_DONTNEED); err != nil {
+		return from, fmt.Errorf("could not drop processed pages: %w", err)
+	}
+
+	return to, nil
 }
 
 // processLine parses a single line and updates the stats map.
 func processLine(line string, stats map[string][4]float64) error {
-	lastSemicolon := strings.LastIndex(line, ";")
-	if lastSemicolon == -1 {
-		panic(fmt.Sprintf("could not parse line: %s", line))
-	}
+	p := processor{stats: stats}
+	return p.processLine(line)
+}
 
-	station := line[:lastSemicolon]
-	temperatureStr := line[lastSemicolon+1:]
+// processLine parses a single line according to p.opts and updates p.stats.
+// With opts.ignoreErrors set, a malformed line is counted in p.skipped instead of failing.
+func (p *processor) processLine(line string) error {
+	if p.hot != nil {
+		p.hot.add(line) // as read, before any cleanup
+	}
+	if p.opts.sanitize {
+		var keep bool
+		if line, keep = sanitizeLine(line); !keep {
+			return nil
+		}
+	}
+	p.lines++
+	if p.rng != nil && p.rng.Float64() >= p.opts.sampleRate {
+		return nil // not sampled
+	}
 
-	temperature, err := strconv.ParseFloat(temperatureStr, 64)
+	weight := 1.0
+	var seconds int64
+	var err error
+	measurement := line // without the trailing fields, while line stays whole for the tee
+	if p.opts.weighted {
+		measurement, weight, err = splitWeight(line, p.opts.separator())
+	} else if p.opts.checkOrder {
+		measurement, seconds, err = splitTimestamp(line, p.opts.separator())
+	}
+	var station string
+	var temperature float64
+	if err == nil {
+		station, temperature, err = p.parseLine(measurement)
+	}
 	if err != nil {
-		panic(fmt.Sprintf("could not parse temperature: %v", err))
+		if p.opts.ignoreErrors {
+			p.skipped++
+			return nil
+		}
+		return err
+	}
+	if math.IsNaN(temperature) {
+		// NaN passes the range check, and math.Min, math.Max and the sum would all turn
+		// into NaN with it, so it is kept out of the aggregates and only counted.
+		p.nans++
+		return nil
+	}
+
+	if p.opts.utf8Replace && !utf8.ValidString(station) {
+		station = strings.ToValidUTF8(station, string(utf8.RuneError))
+	}
+
+	if !p.opts.keepStation(station) {
+		return nil // filtered out before any aggregation work
+	}
+
+	if p.lastSeen != nil {
+		p.observeOrder(station, seconds)
+	}
+
+	if p.opts.quantize > 0 {
+		temperature = math.Round(temperature/p.opts.quantize) * p.opts.quantize
+	}
+
+	if p.tee != nil {
+		// Write errors are sticky, so they are reported when the tee is flushed.
+		_, _ = p.tee.WriteString(line)
+		_ = p.tee.WriteByte('\n')
+	}
+
+	if p.groups != nil {
+		return p.groups.add(station, temperature)
+	}
+	if p.top != nil {
+		p.top.add(station, temperature)
+		return nil
+	}
+	if p.hll != nil {
+		p.hll.add(station)
+		return nil
+	}
+
+	p.aggregate(station, temperature)
+	if p.weighted != nil {
+		if _, kept := p.stats[station]; kept { // not dropped by -limit-stations
+			p.addToWeightedHistogram(station, temperature, weight)
+		}
+	}
+	return nil
+}
+
+// The valid temperature range of the 1BRC input.
+const (
+	minTemperature = -99.9
+	maxTemperature = 99.9
+)
+
+// parseLine splits a line into its station key and temperature according to p.opts.
+// It fails on a missing separator, an unparsable number or a temperature outside
+// [minTemperature, maxTemperature].
+func (p *processor) parseLine(line string) (string, float64, error) {
+	if p.opts.jsonl {
+		station, temperature, err := parseJSONLine(line)
+		if err != nil {
+			return "", 0, err
+		}
+		return p.checkRange(station, temperature, line)
+	}
+
+	sep := p.opts.separator()
+	if p.opts.byHour {
+		key, rest, err := splitHourKey(line, sep)
+		if err != nil {
+			return "", 0, err
+		}
+		line = key + rest
+	}
+
+	if sep == ' ' {
+		// Whitespace-separated: station names may contain spaces, so runs of spaces around
+		// the fields are insignificant and only the last (or, with -temp-first, the first)
+		// space splits the line.
+		line = strings.Trim(line, " ")
+	}
+
+	var station, temperatureStr string
+	if p.opts.tempFirst {
+		firstSep := strings.IndexByte(line, sep)
+		if firstSep == -1 && p.opts.sep2 != 0 {
+			firstSep = strings.IndexByte(line, p.opts.sep2) // fall back to the secondary separator
+		}
+		if firstSep == -1 {
+			return "", 0, fmt.Errorf("could not parse line: %s", line)
+		}
+		temperatureStr, station = line[:firstSep], line[firstSep+1:]
+	} else {
+		lastSep := strings.LastIndexByte(line, sep)
+		if lastSep == -1 && p.opts.sep2 != 0 {
+			lastSep = strings.LastIndexByte(line, p.opts.sep2) // fall back to the secondary separator
+		}
+		if lastSep == -1 {
+			return "", 0, fmt.Errorf("could not parse line: %s", line)
+		}
+		station, temperatureStr = line[:lastSep], line[lastSep+1:]
+	}
+	if sep == ' ' {
+		station = strings.Trim(station, " ")
+	}
+	if p.opts.sanitize {
+		station, temperatureStr = strings.TrimSpace(station), strings.TrimSpace(temperatureStr)
+	}
+
+	fahrenheit, kelvin := false, p.opts.kelvin
+	if p.opts.unitSuffix && len(temperatureStr) > 0 {
+		switch temperatureStr[len(temperatureStr)-1] {
+		case 'C':
+			temperatureStr = temperatureStr[:len(temperatureStr)-1]
+			kelvin = false
+		case 'F':
+			temperatureStr = temperatureStr[:len(temperatureStr)-1]
+			fahrenheit, kelvin = true, false
+		}
+	}
+
+	if err := checkTempDigits(temperatureStr, p.opts.maxTempDigits); err != nil {
+		return "", 0, err
 	}
 
+	var temperature float64
+	if p.opts.tenths {
+		tenths, err := strconv.Atoi(temperatureStr)
+		if err != nil {
+			return "", 0, fmt.Errorf("could not parse temperature: %w", err)
+		}
+		temperature = float64(tenths) / 10
+	} else {
+		var err error
+		if temperature, err = strconv.ParseFloat(temperatureStr, 64); err != nil {
+			return "", 0, fmt.Errorf("could not parse temperature: %w", err)
+		}
+	}
+	if fahrenheit {
+		temperature = (temperature - 32) * 5 / 9
+	}
+	if kelvin {
+		temperature = kelvinToCelsius(temperature, temperatureStr)
+	}
+	return p.checkRange(station, temperature, line)
+}
+
+// outOfRange reports whether temperature lies outside [minTemperature, maxTemperature].
+// NaN doesn't: callers keep it out of the aggregates separately.
+func outOfRange(temperature float64) bool {
+	return temperature < minTemperature || temperature > maxTemperature
+}
+
+// checkRange fails on a temperature of line outside [minTemperature, maxTemperature], or
+// clamps it with -clamp.
+func (p *processor) checkRange(station string, temperature float64, line string) (string, float64, error) {
+	if outOfRange(temperature) {
+		if !p.opts.clamp {
+			return "", 0, fmt.Errorf("temperature out of range: %s", line)
+		}
+		temperature = min(max(temperature, minTemperature), maxTemperature)
+		p.clamped++
+	}
+
+	return station, temperature, nil
+}
+
+// kelvinOffset is 0°C in Kelvin.
+const kelvinOffset = 273.15
+
+// kelvinToCelsius converts the reading parsed from s. The exact decimal difference has no
+// more decimal places than s or kelvinOffset, so the result is rounded to those to drop the
+// float error, e.g. 285.15 becomes 12 rather than 11.999999999999977.
+func kelvinToCelsius(kelvin float64, s string) float64 {
+	decimals := 2 // kelvinOffset's
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		decimals = max(decimals, len(s)-dot-1)
+	}
+	scale := math.Pow10(decimals)
+	return math.Round((kelvin-kelvinOffset)*scale) / scale
+}
+
+// aggregate adds a single parsed reading to the station's statistics.
+func (p *processor) aggregate(station string, temperature float64) {
+	stats := p.stats
+
 	// Get or create the tuple this station [min, sum, count, max]
 	tup, exists := stats[station]
 	if !exists {
+		if p.opts.limitStations > 0 && len(stats) >= p.opts.limitStations {
+			return // the station cap is reached, later stations are dropped
+		}
 		// Initialize with default values (min=MAX, sum=0, count=0, max=MIN)
 		tup = [4]float64{
 			float64(^uint(0) >> 1),  // min
@@ -170,34 +1602,119 @@ func processLine(line string, stats map[string][4]float64) error {
 	tup[2] += 1.0                          // count
 	tup[3] = math.Max(tup[3], temperature) // max
 
+	if p.sums != nil {
+		tup[1] = p.addCompensated(station, temperature) // replace the naive sum
+	}
+
 	stats[station] = tup // <-- put the updated tup back in map
 
-	return nil
+	if p.hists != nil {
+		p.addToHistogram(station, temperature)
+	}
+	if p.signs != nil {
+		counts, exists := p.signs[station]
+		if !exists {
+			counts = new([2]int64)
+			p.signs[station] = counts
+		}
+		if temperature < 0 {
+			counts[0]++
+		} else {
+			counts[1]++
+		}
+	}
+	if p.firstLast != nil {
+		values, exists := p.firstLast[station]
+		if !exists {
+			values = &[2]float64{temperature} // first, set once
+			p.firstLast[station] = values
+		}
+		values[1] = temperature // last, overwritten by every reading
+	}
+	if p.squares != nil {
+		p.squares[station] += temperature * temperature
+	}
+}
+
+// splitHourKey splits a `station;temp;unixSeconds` line, with sep as the separator, into
+// the composite `station@HH` key and the remaining `;temp` part.
+//
+// The hour of day is derived from the timestamp in UTC and zero-padded, so sorting
+// the composite keys orders each station's buckets chronologically.
+func splitHourKey(line string, sep byte) (key, rest string, err error) {
+	lastSep := strings.LastIndexByte(line, sep)
+	if lastSep == -1 {
+		return "", "", fmt.Errorf("could not parse timestamp in line: %s", line)
+	}
+
+	seconds, err := strconv.ParseInt(line[lastSep+1:], 10, 64)
+	if err != nil {
+		return "", "", fmt.Errorf("could not parse timestamp: %w", err)
+	}
+
+	line = line[:lastSep]
+	tempSep := strings.LastIndexByte(line, sep)
+	if tempSep == -1 {
+		return "", "", fmt.Errorf("could not parse line: %s", line)
+	}
+
+	const secondsPerDay, secondsPerHour = 24 * 60 * 60, 60 * 60
+	secondOfDay := seconds % secondsPerDay
+	if secondOfDay < 0 { // timestamps before the epoch
+		secondOfDay += secondsPerDay
+	}
+	hour := secondOfDay / secondsPerHour
+
+	return fmt.Sprintf("%s@%02d", line[:tempSep], hour), line[tempSep:], nil
+}
+
+// StationStat is the summarized statistics of a single station.
+type StationStat struct {
+	Name  string
+	Min   float64
+	Mean  float64
+	Max   float64
+	Count int64
+}
+
+// SortedStats summarizes the [min, sum, count, max] tuples into StationStats sorted by name.
+func SortedStats(stats map[string][4]float64) []StationStat {
+	sorted := make([]StationStat, 0, len(stats))
+	for station, tup := range stats {
+		sorted = append(sorted, StationStat{
+			Name:  station,
+			Min:   tup[0],
+			Mean:  tup[1] / tup[2],
+			Max:   tup[3],
+			Count: int64(tup[2]),
+		})
+	}
+	slices.SortFunc(sorted, func(a, b StationStat) int { return compareStations(a, b, SortByName) })
+
+	return sorted
 }
 
 // formatOutput formats the statistics into the required output format.
 func formatOutput(stats map[string][4]float64) string {
-	stations := make([]string, 0, len(stats))
-	for station := range stats {
-		stations = append(stations, station)
-	}
-	sort.Strings(stations)
+	return formatOutputWith(stats, outputOptions{})
+}
+
+// formatOutputWith formats the statistics like formatOutput, ordered and annotated
+// according to out.
+func formatOutputWith(stats map[string][4]float64, out outputOptions) string {
+	stations := out.stations(stats)
 
 	var output strings.Builder
 	output.WriteString("{")
 
 	for i, station := range stations {
-		tup := stats[station]
-		minn := tup[0]
-		sum := tup[1]
-		count := tup[2]
-		maxx := tup[3]
-		mean := sum / count
-
-		output.WriteString(fmt.Sprintf("%s=%.1f/%.1f/%.1f", station, minn, mean, maxx))
+		output.WriteString(out.displayName(station.Name) + "=" + out.values(station))
+		if out.annotate != nil {
+			output.WriteString(out.annotate(station.Name))
+		}
 
 		if i < len(stations)-1 {
-			output.WriteString(", ")
+			output.WriteString(out.entrySeparator())
 		}
 	}
 
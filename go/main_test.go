@@ -1,11 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"math"
 	"os"
+	"path/filepath"
 	"strings"
+	"syscall"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/require"
 )
@@ -19,7 +24,8 @@ func TestMMapFile_SmallContent(t *testing.T) {
 	file := createTestFile(t, content)
 	defer cleanupTestFile(t, file)
 
-	mmap := mmapFile(file)
+	mmap, err := mmapFile(file)
+	require.NoError(t, err)
 
 	require.Equal(t, len(mmap), len(content))
 	require.Equal(t, content, string(mmap))
@@ -32,7 +38,8 @@ func TestMMapFile_UnicodeContent(t *testing.T) {
 	file := createTestFile(t, content)
 	defer cleanupTestFile(t, file)
 
-	mmap := mmapFile(file)
+	mmap, err := mmapFile(file)
+	require.NoError(t, err)
 	require.Equal(t, len(mmap), len(content))
 	require.Equal(t, content, string(mmap))
 }
@@ -47,7 +54,8 @@ func TestMMapFile_LargeContent(t *testing.T) {
 	file := createTestFile(t, content)
 	defer cleanupTestFile(t, file)
 
-	mmap := mmapFile(file)
+	mmap, err := mmapFile(file)
+	require.NoError(t, err)
 	require.Equal(t, len(mmap), len(content))
 	require.Equal(t, content, string(mmap))
 	// Check first, middle, and last bytes
@@ -61,7 +69,8 @@ func TestMMapFile_LineParsingWithMMapData(t *testing.T) {
 	file := createTestFile(t, "Station1;10.5\nStation2;-3.2\n\nStation3;0.0\n")
 	defer cleanupTestFile(t, file)
 
-	mmap := mmapFile(file)
+	mmap, err := mmapFile(file)
+	require.NoError(t, err)
 	lines := strings.Split(string(mmap), "\n")
 
 	// The data "Station1;10.5\nStation2;-3.2\n\nStation3;0.0\n" splits into:
@@ -225,6 +234,248 @@ func TestProcessLine_NegativeTemperatures(t *testing.T) {
 	}
 }
 
+// TestProcessLine_ByHour tests that -by-hour aggregates each station per hour of day.
+func TestProcessLine_ByHour(t *testing.T) {
+	p := newProcessor(options{byHour: true})
+
+	lines := []string{
+		"Berlin;10.0;1700000000", // 22:13 UTC
+		"Berlin;14.0;1700001000", // 22:30 UTC
+		"Berlin;20.0;1700017200", // 03:00 UTC next day
+		"Hamburg;5.0;1700017200", // 03:00 UTC
+	}
+	for _, line := range lines {
+		require.NoError(t, p.processLine(line))
+	}
+
+	require.Len(t, p.stats, 3)
+	require.Equal(t, [4]float64{10.0, 24.0, 2.0, 14.0}, p.stats["Berlin@22"])
+	require.Equal(t, [4]float64{20.0, 20.0, 1.0, 20.0}, p.stats["Berlin@03"])
+	require.Equal(t, [4]float64{5.0, 5.0, 1.0, 5.0}, p.stats["Hamburg@03"])
+
+	output := formatOutput(p.stats)
+	require.Equal(t, "{Berlin@03=20.0/20.0/20.0, Berlin@22=10.0/12.0/14.0, Hamburg@03=5.0/5.0/5.0}", output)
+
+	require.Error(t, p.processLine("Berlin;10.0"))
+	require.Error(t, p.processLine("Berlin;10.0;noon"))
+}
+
+// TestProcessLine_Malformed tests the errors reported for malformed lines.
+func TestProcessLine_Malformed(t *testing.T) {
+	stats := make(map[string][4]float64)
+
+	require.EqualError(t, processLine("Hamburg 12.0", stats), "could not parse line: Hamburg 12.0")
+	require.ErrorContains(t, processLine("Hamburg;twelve", stats), "could not parse temperature")
+	require.EqualError(t, processLine("Hamburg;100.0", stats), "temperature out of range: Hamburg;100.0")
+	require.EqualError(t, processLine("Hamburg;-99.95", stats), "temperature out of range: Hamburg;-99.95")
+	require.NoError(t, processLine("Hamburg;-99.9", stats))
+	require.NoError(t, processLine("Hamburg;99.9", stats))
+	require.Len(t, stats, 1)
+}
+
+// TestProcessLine_IgnoreErrors tests that -ignore-errors skips and counts every malformed line.
+func TestProcessLine_IgnoreErrors(t *testing.T) {
+	p := newProcessor(options{ignoreErrors: true})
+
+	lines := []string{"garbage", "Hamburg;12.0", "Berlin;", ";;;", "Oslo;1e999", "Tokyo;250.0", "Hamburg;8.0", "\x00\xff"}
+	for _, line := range lines {
+		require.NoError(t, p.processLine(line))
+	}
+
+	require.Equal(t, int64(6), p.skipped)
+	require.Equal(t, "{Hamburg=8.0/10.0/12.0}", formatOutput(p.stats))
+}
+
+// TestProcessLine_UnitSuffix tests that -unit-suffix normalizes mixed-unit readings to Celsius.
+func TestProcessLine_UnitSuffix(t *testing.T) {
+	p := newProcessor(options{unitSuffix: true})
+
+	require.NoError(t, p.processLine("Berlin;12.0C"))
+	require.NoError(t, p.processLine("Berlin;53.6F")) // == 12.0C
+	require.NoError(t, p.processLine("Berlin;12.0"))  // no suffix is Celsius
+
+	tup := p.stats["Berlin"]
+	require.InDelta(t, 12.0, tup[0], 1e-9)
+	require.InDelta(t, 12.0, tup[3], 1e-9)
+	require.InDelta(t, 36.0, tup[1], 1e-9)
+	require.Equal(t, 3.0, tup[2])
+
+	require.NoError(t, p.processLine("Tokyo;-40F"))
+	require.InDelta(t, -40.0, p.stats["Tokyo"][0], 1e-9)
+
+	require.Error(t, p.processLine("Tokyo;212F"), "100C is out of range once converted")
+	require.Error(t, p.processLine("Tokyo;12.0K"))
+	require.Error(t, processLine("Berlin;12.0C", p.stats), "suffixes are rejected without the option")
+}
+
+// TestProcessLine_NaN tests that NaN readings are counted but leave min, max and the
+// mean of their station untouched, on both paths.
+func TestProcessLine_NaN(t *testing.T) {
+	for _, assumeASCII := range []bool{false, true} {
+		p := newProcessor(options{assumeASCII: assumeASCII})
+		p.asciiStats = make(map[string]*[4]float64)
+		process := p.processLine
+		if assumeASCII {
+			process = func(line string) error { return p.processASCIILine([]byte(line)) }
+		}
+
+		for _, line := range []string{"Berlin;12.0", "Berlin;NaN", "Berlin;-3.0", "Berlin;nan", "Oslo;NaN"} {
+			require.NoError(t, process(line))
+		}
+		p.flushASCII()
+
+		require.Equal(t, map[string][4]float64{"Berlin": {-3.0, 9.0, 2, 12.0}}, p.stats, "assume-ascii=%t", assumeASCII)
+		require.Equal(t, int64(3), p.nans)
+		require.Equal(t, int64(5), p.lines)
+	}
+}
+
+// TestProcessLine_Kelvin tests that -input-unit K converts readings to Celsius without
+// float error, while -unit-suffix readings keep their unit.
+func TestProcessLine_Kelvin(t *testing.T) {
+	p := newProcessor(options{kelvin: true})
+
+	require.NoError(t, p.processLine("Berlin;285.15"))
+	require.NoError(t, p.processLine("Berlin;273.1"))
+	require.NoError(t, p.processLine("Oslo;260.125"))
+	require.Equal(t, [4]float64{-0.05, 11.95, 2, 12.0}, p.stats["Berlin"])
+	require.Equal(t, -13.025, p.stats["Oslo"][0], "more decimals than the offset are kept")
+
+	require.Error(t, p.processLine("Tokyo;12.0"), "-261.15C is out of range")
+
+	p = newProcessor(options{kelvin: true, unitSuffix: true})
+	require.NoError(t, p.processLine("Berlin;12.0C"))
+	require.NoError(t, p.processLine("Berlin;285.15"))
+	require.Equal(t, [4]float64{12.0, 24.0, 2, 12.0}, p.stats["Berlin"])
+}
+
+// TestProcessLine_SecondarySeparator tests that -sep2 is tried when -sep isn't found.
+func TestProcessLine_SecondarySeparator(t *testing.T) {
+	p := newProcessor(options{sep: ';', sep2: ','})
+
+	for _, line := range []string{"Hamburg;12.0", "Hamburg,8.0", "Berlin,20.0", "Washington, D.C.;15.0"} {
+		require.NoError(t, p.processLine(line))
+	}
+
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0, Washington, D.C.=15.0/15.0/15.0}", formatOutput(p.stats))
+	require.Error(t, p.processLine("Hamburg|12.0"))
+	require.Error(t, newProcessor(options{sep: ';'}).processLine("Hamburg,8.0"), "no fallback without -sep2")
+}
+
+// TestProcessLine_Quantize tests that temperatures are rounded to the -quantize step before aggregating.
+func TestProcessLine_Quantize(t *testing.T) {
+	p := newProcessor(options{quantize: 0.5})
+	require.NoError(t, p.processLine("Hamburg;12.3"))
+	require.NoError(t, p.processLine("Hamburg;12.4"))
+	require.NoError(t, p.processLine("Oslo;-3.7"))
+
+	require.Equal(t, [4]float64{12.5, 25.0, 2.0, 12.5}, p.stats["Hamburg"])
+	require.Equal(t, [4]float64{-3.5, -3.5, 1.0, -3.5}, p.stats["Oslo"])
+}
+
+// TestProcessLine_LimitStations tests that only the first N stations by appearance are kept,
+// while readings of already-seen stations are still aggregated.
+func TestProcessLine_LimitStations(t *testing.T) {
+	p := newProcessor(options{limitStations: 2})
+	for _, line := range []string{"Oslo;1.0", "Berlin;20.0", "Hamburg;12.0", "Oslo;3.0", "Athens;30.0", "Berlin;25.0"} {
+		require.NoError(t, p.processLine(line))
+	}
+
+	require.Equal(t, "{Berlin=20.0/22.5/25.0, Oslo=1.0/2.0/3.0}", formatOutput(p.stats))
+}
+
+// TestProcessLine_Tenths tests parsing temperatures given as integer tenths.
+func TestProcessLine_Tenths(t *testing.T) {
+	p := newProcessor(options{tenths: true})
+	require.NoError(t, p.processLine("Berlin;125"))
+	require.NoError(t, p.processLine("Berlin;-57"))
+	require.NoError(t, p.processLine("Oslo;0"))
+
+	require.Equal(t, "{Berlin=-5.7/3.4/12.5, Oslo=0.0/0.0/0.0}", formatOutput(p.stats))
+	require.Error(t, p.processLine("Berlin;12.5"), "a decimal point isn't allowed")
+	require.Error(t, p.processLine("Berlin;1000"), "still range checked")
+}
+
+// TestProcessLine_Clamp tests that -clamp aggregates out-of-range temperatures at the range limits and counts them.
+func TestProcessLine_Clamp(t *testing.T) {
+	p := newProcessor(options{clamp: true})
+	for _, line := range []string{"Hamburg;150.0", "Hamburg;-200.0", "Hamburg;12.0"} {
+		require.NoError(t, p.processLine(line))
+	}
+
+	require.Equal(t, [4]float64{-99.9, 12.0, 3.0, 99.9}, p.stats["Hamburg"])
+	require.Equal(t, int64(2), p.clamped)
+}
+
+// TestProcessLine_TempFirst tests parsing `temp;station` lines with -temp-first, where the
+// station name keeps any later separators.
+func TestProcessLine_TempFirst(t *testing.T) {
+	p := newProcessor(options{tempFirst: true})
+	for _, line := range []string{"12.0;Berlin", "-4.0;Berlin", "7.0;Rio;de;Janeiro"} {
+		require.NoError(t, p.processLine(line))
+	}
+
+	require.Equal(t, "{Berlin=-4.0/4.0/12.0, Rio;de;Janeiro=7.0/7.0/7.0}", formatOutput(p.stats))
+	require.Error(t, p.processLine("Berlin;12.0"), "the station isn't a temperature")
+}
+
+// TestProcessLine_BySign tests that -by-sign counts readings below zero separately from
+// those at or above zero, including across merged processors.
+func TestProcessLine_BySign(t *testing.T) {
+	p := newProcessor(options{bySign: true})
+	for _, line := range []string{"Oslo;-5.0", "Oslo;0.0", "Oslo;-0.1", "Oslo;3.5", "Oslo;-12.0", "Rome;20.0"} {
+		require.NoError(t, p.processLine(line))
+	}
+	require.Equal(t, [2]int64{3, 2}, *p.signs["Oslo"])
+	require.Equal(t, [2]int64{0, 1}, *p.signs["Rome"])
+
+	other := newProcessor(options{bySign: true})
+	require.NoError(t, other.processLine("Oslo;-1.0"))
+	p.merge(other)
+
+	require.Equal(t,
+		"{Oslo=-12.0/-2.4/3.5 negative=4 non-negative=2, Rome=20.0/20.0/20.0 negative=0 non-negative=1}",
+		formatOutputWith(p.stats, outputOptions{annotate: p.annotate}),
+	)
+}
+
+// TestProcessLine_FirstLast tests that -first-last keeps each station's first reading and
+// overwrites the last one, independently of the min and max, including across merged processors.
+func TestProcessLine_FirstLast(t *testing.T) {
+	p := newProcessor(options{firstLast: true})
+	for _, line := range []string{"Oslo;-5.0", "Rome;20.0", "Oslo;3.5", "Oslo;-12.0", "Oslo;0.5"} {
+		require.NoError(t, p.processLine(line))
+	}
+	require.Equal(t, [2]float64{-5.0, 0.5}, *p.firstLast["Oslo"])
+	require.Equal(t, [2]float64{20.0, 20.0}, *p.firstLast["Rome"])
+
+	other := newProcessor(options{firstLast: true})
+	require.NoError(t, other.processLine("Oslo;7.0"))
+	require.NoError(t, other.processLine("Bern;1.0"))
+	p.merge(other)
+
+	require.Equal(t,
+		"{Bern=1.0/1.0/1.0 first=1.0 last=1.0, Oslo=-12.0/-1.2/7.0 first=-5.0 last=7.0, Rome=20.0/20.0/20.0 first=20.0 last=20.0}",
+		formatOutputWith(p.stats, outputOptions{annotate: p.annotate}),
+	)
+}
+
+// TestSortedStats tests that SortedStats summarizes and sorts stations by name.
+func TestSortedStats(t *testing.T) {
+	stats := map[string][4]float64{
+		"Hamburg":    {5.0, 30.0, 3.0, 15.0},
+		"Berlin":     {10.0, 45.0, 3.0, 20.0},
+		"Copenhagen": {0.0, 10.0, 4.0, 10.0},
+	}
+
+	expected := []StationStat{
+		{Name: "Berlin", Min: 10.0, Mean: 15.0, Max: 20.0, Count: 3},
+		{Name: "Copenhagen", Min: 0.0, Mean: 2.5, Max: 10.0, Count: 4},
+		{Name: "Hamburg", Min: 5.0, Mean: 10.0, Max: 15.0, Count: 3},
+	}
+	require.Equal(t, expected, SortedStats(stats))
+}
+
 // TestFormatOutput_SingleStation tests formatting output for a single station.
 func TestFormatOutput_SingleStation(t *testing.T) {
 	stats := map[string][4]float64{
@@ -283,6 +534,24 @@ func TestFormatOutput_Empty(t *testing.T) {
 
 // -------------------------------------------- Integration Tests --------------------------------------------
 
+// TestProcessFileInto tests that two files processed into the same map accumulate.
+func TestProcessFileInto(t *testing.T) {
+	first := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\n")
+	defer cleanupTestFile(t, first)
+	second := createTestFile(t, "Hamburg;8.0\nOslo;-5.0\n")
+	defer cleanupTestFile(t, second)
+
+	dst := map[string][4]float64{"Berlin": {25.0, 25.0, 1.0, 25.0}} // pre-populated
+	require.NoError(t, ProcessFileInto(first.Name(), dst))
+	require.NoError(t, ProcessFileInto(second.Name(), dst))
+
+	require.Equal(t, map[string][4]float64{
+		"Berlin":  {20.0, 45.0, 2.0, 25.0},
+		"Hamburg": {8.0, 20.0, 2.0, 12.0},
+		"Oslo":    {-5.0, -5.0, 1.0, -5.0},
+	}, dst)
+}
+
 // TestProcessFile_Integration tests the full file processing pipeline.
 func TestProcessFile_Integration(t *testing.T) {
 	data := "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\nBerlin;25.0\n"
@@ -347,6 +616,56 @@ func TestMMapFile_WithMMapIntegration(t *testing.T) {
 	require.Contains(t, stats, "C")
 }
 
+// TestProcessFile_DropPages tests that -drop-pages releases scanned pages without affecting the stats.
+func TestProcessFile_DropPages(t *testing.T) {
+	var data strings.Builder
+	for i := 0; i < 2_000; i++ {
+		data.WriteString("Hamburg;12.0\nBerlin;-4.0\n")
+	}
+	file := createTestFile(t, data.String())
+	defer cleanupTestFile(t, file)
+
+	p := newProcessor(options{dropPages: true})
+	p.dropWindow = os.Getpagesize() // drop after every page to exercise the path repeatedly
+	require.NoError(t, p.processFile(file.Name()))
+
+	require.Equal(t, [4]float64{12.0, 24_000.0, 2_000.0, 12.0}, p.stats["Hamburg"])
+	require.Equal(t, [4]float64{-4.0, -8_000.0, 2_000.0, -4.0}, p.stats["Berlin"])
+}
+
+// TestDropPages tests the page-aligned watermark returned by dropPages.
+func TestDropPages(t *testing.T) {
+	pageSize := os.Getpagesize()
+	file := createTestFile(t, strings.Repeat("x", 3*pageSize))
+	defer cleanupTestFile(t, file)
+
+	mmap, err := mmapFile(file)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, syscall.Munmap(mmap)) }()
+
+	dropped, err := dropPages(mmap, 0, pageSize-1) // less than one page: nothing to drop
+	require.NoError(t, err)
+	require.Equal(t, 0, dropped)
+
+	dropped, err = dropPages(mmap, dropped, 2*pageSize+10)
+	require.NoError(t, err)
+	require.Equal(t, 2*pageSize, dropped)
+
+	// Dropped pages fault back in from the file.
+	require.Equal(t, byte('x'), mmap[0])
+	require.Equal(t, byte('x'), mmap[pageSize])
+}
+
+// TestProcessFile_Empty tests that an empty file yields no stations.
+func TestProcessFile_Empty(t *testing.T) {
+	file := createTestFile(t, "")
+	defer cleanupTestFile(t, file)
+
+	stats, err := processFile(file.Name())
+	require.NoError(t, err)
+	require.Empty(t, stats)
+}
+
 // TestFullPipeline tests the complete pipeline from file to formatted output.
 func TestFullPipeline(t *testing.T) {
 	data := "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\nBerlin;25.0\n"
@@ -385,6 +704,231 @@ func TestFullPipeline_WithNegatives(t *testing.T) {
 	}
 }
 
+// TestRun_IgnoreErrors tests that a mostly garbage file completes and reports the skip count.
+func TestRun_IgnoreErrors(t *testing.T) {
+	var data strings.Builder
+	for i := 0; i < 100; i++ {
+		fmt.Fprintf(&data, "junk line %d\n", i)
+		if i%10 == 0 {
+			data.WriteString("Oslo;-5.0\n")
+		}
+	}
+	file := createTestFile(t, data.String())
+	defer cleanupTestFile(t, file)
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"-ignore-errors", file.Name()}, nil, &stdout, &stderr))
+	require.Equal(t, "{Oslo=-5.0/-5.0/-5.0}\n\n", stdout.String())
+	require.Equal(t, "skipped 100 malformed lines\n", stderr.String())
+
+	require.Error(t, run([]string{file.Name()}, nil, &stdout, &stderr))
+}
+
+// TestRun_Separators tests -sep and -sep2 on a file mixing both separators.
+func TestRun_Separators(t *testing.T) {
+	file := createTestFile(t, "Hamburg|12.0\nBerlin,20.0\nHamburg,8.0\nBerlin|25.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-sep", "|", "-sep2", ",", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.0/22.5/25.0, Hamburg=8.0/10.0/12.0}\n\n", stdout.String())
+
+	err := run([]string{"-sep", "::", file.Name()}, nil, &stdout, &bytes.Buffer{})
+	require.EqualError(t, err, `-sep must be a single byte, got "::"`)
+}
+
+// TestProcessLine_SpaceSeparator tests that with a space separator the line splits on its
+// last space, so station names keep their spaces and extra spaces are ignored.
+func TestProcessLine_SpaceSeparator(t *testing.T) {
+	p := newProcessor(options{sep: ' '})
+
+	station, temperature, err := p.parseLine("New York 12.0")
+	require.NoError(t, err)
+	require.Equal(t, "New York", station)
+	require.Equal(t, 12.0, temperature)
+
+	for _, line := range []string{"New York 8.0  ", "  New York   10.0"} {
+		require.NoError(t, p.processLine(line))
+	}
+	require.Equal(t, "{New York=8.0/9.0/10.0}", formatOutput(p.stats))
+}
+
+// TestRun_UTF8Replace tests that -utf8-replace sanitizes invalid bytes in station names,
+// merging the readings of names that become equal.
+func TestRun_UTF8Replace(t *testing.T) {
+	file := createTestFile(t, "Z\xffrich;12.0\nZ\xferich;8.0\nBerlin;20.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-utf8-replace", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Z\uFFFDrich=8.0/10.0/12.0}\n\n", stdout.String())
+	require.True(t, utf8.Valid(stdout.Bytes()))
+}
+
+// TestRun_InputUnit tests that -input-unit K aggregates Kelvin readings as Celsius.
+func TestRun_InputUnit(t *testing.T) {
+	file := createTestFile(t, "Station;285.15\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-input-unit", "K", "-assume-ascii", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Station=12.0/12.0/12.0}\n\n", stdout.String())
+
+	err := run([]string{"-input-unit", "F", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.EqualError(t, err, `-input-unit must be C or K, got "F"`)
+}
+
+// TestRun_FailOnEmpty tests that an empty file prints {} by default and fails with
+// -fail-on-empty.
+func TestRun_FailOnEmpty(t *testing.T) {
+	file := createTestFile(t, "")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{}\n\n", stdout.String())
+
+	stdout.Reset()
+	err := run([]string{"-fail-on-empty", file.Name()}, nil, &stdout, &bytes.Buffer{})
+	require.EqualError(t, err, "no stations were aggregated (-fail-on-empty)")
+	require.Empty(t, stdout.String())
+
+	var stderr bytes.Buffer
+	require.Equal(t, 1, runMain([]string{"-fail-on-empty", file.Name()}, nil, &bytes.Buffer{}, &stderr))
+	require.Contains(t, stderr.String(), file.Name(), "the report names the input")
+}
+
+// TestRun_FirstLast tests that -first-last annotates the output and rejects inputs whose
+// readings can't be seen in order.
+func TestRun_FirstLast(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\nHamburg;10.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-first-last", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0 first=20.0 last=20.0, Hamburg=8.0/10.0/12.0 first=12.0 last=10.0}\n\n", stdout.String())
+
+	err := run([]string{"-first-last", "-workers", "2", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-first-last can't be combined with -workers")
+
+	err = run([]string{"-first-last", t.TempDir()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-first-last requires a single input file or stdin")
+}
+
+// TestRun_NaN tests that ignored NaN readings are reported on stderr.
+func TestRun_NaN(t *testing.T) {
+	file := createTestFile(t, "Berlin;12.0\nBerlin;NaN\nBerlin;8.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{file.Name()}, nil, &stdout, &stderr))
+	require.Equal(t, "{Berlin=8.0/10.0/12.0}\n\n", stdout.String())
+	require.Equal(t, "ignored 1 NaN temperatures\n", stderr.String())
+}
+
+// TestRun_Tee tests that -tee copies exactly the valid lines, in order, while aggregating them.
+func TestRun_Tee(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nbroken\nBerlin;20.0\nOslo;120.0\nHamburg;8.0")
+	defer cleanupTestFile(t, file)
+	tee := filepath.Join(t.TempDir(), "clean.txt")
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-ignore-errors", "-tee", tee, file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n\n", stdout.String())
+
+	data, err := os.ReadFile(tee)
+	require.NoError(t, err)
+	require.Equal(t, "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\n", string(data))
+}
+
+// TestRunMain_JSONError tests that a failure is reported as a single-line JSON object with a nonzero exit code.
+func TestRunMain_JSONError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.txt")
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 1, runMain([]string{path}, nil, &stdout, &stderr))
+	require.Empty(t, stdout.String())
+
+	require.Equal(t, 1, strings.Count(stderr.String(), "\n"), "a single line")
+	var report map[string]string
+	require.NoError(t, json.Unmarshal(stderr.Bytes(), &report))
+	require.Equal(t, path, report["path"])
+	require.Contains(t, report["error"], "could not open file")
+	require.Len(t, report, 2)
+
+	require.Equal(t, 0, runMain([]string{"-h"}, nil, &stdout, &bytes.Buffer{}))
+}
+
+// TestRun_VerboseLogging tests that -v logs every phase to stderr and leaves stdout untouched.
+func TestRun_VerboseLogging(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"-v", file.Name()}, nil, &stdout, &stderr))
+
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=12.0/12.0/12.0}\n\n", stdout.String())
+	for _, phase := range []string{"open", "mmap", "scan", "format"} {
+		require.Contains(t, stderr.String(), "phase="+phase)
+	}
+	require.NotContains(t, stderr.String(), "level=DEBUG")
+}
+
+// TestRun_MixedLineEndings tests that -v warns with the count of each ending when LF and CRLF are mixed.
+func TestRun_MixedLineEndings(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\r\nBerlin;20.0\nHamburg;8.0\r\nBerlin;25.0\r\nOslo;-5.0")
+	defer cleanupTestFile(t, file)
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"-v", file.Name()}, nil, &stdout, &stderr))
+	require.Equal(t, "{Berlin=20.0/22.5/25.0, Hamburg=8.0/10.0/12.0, Oslo=-5.0/-5.0/-5.0}\n\n", stdout.String())
+	require.Contains(t, stderr.String(), `level=WARN msg="mixed line endings"`)
+	require.Contains(t, stderr.String(), "lf=1 crlf=3")
+
+	stderr.Reset()
+	require.NoError(t, run([]string{file.Name()}, nil, &stdout, &stderr))
+	require.Empty(t, stderr.String(), "silent without -v")
+}
+
+// TestRun_CRLFEveryPath tests that CRLF line endings, including a final line ending in a bare
+// '\r', are accepted alike from a file, stdin, a tar archive and -workers chunks.
+func TestRun_CRLFEveryPath(t *testing.T) {
+	const data = "Hamburg;12.0\r\nBerlin;20.0\r\nHamburg;8.0\r"
+	const want = "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n\n"
+	file := createTestFile(t, data)
+	defer cleanupTestFile(t, file)
+	archive := filepath.Join(t.TempDir(), "shards.tar")
+	require.NoError(t, os.WriteFile(archive, buildTar(t, map[string]string{"a.txt": data}), 0o600))
+
+	for name, args := range map[string][]string{
+		"file":    {file.Name()},
+		"stdin":   {stdinPath},
+		"tar":     {archive},
+		"workers": {"-workers", "2", file.Name()},
+	} {
+		var stdout, stderr bytes.Buffer
+		require.NoError(t, run(append([]string{"-v"}, args...), strings.NewReader(data), &stdout, &stderr), name)
+		require.Equal(t, want, stdout.String(), name)
+		require.NotContains(t, stderr.String(), "mixed line endings", name)
+	}
+}
+
+// TestNewLogger_Levels tests which records each verbosity level lets through.
+func TestNewLogger_Levels(t *testing.T) {
+	var buf bytes.Buffer
+
+	newLogger(&buf, 0).Info("silent")
+	require.Empty(t, buf.String())
+
+	newLogger(&buf, 1).Debug("hidden")
+	newLogger(&buf, 1).Info("shown", "phase", "scan")
+	require.NotContains(t, buf.String(), "hidden")
+	require.Contains(t, buf.String(), "msg=shown phase=scan")
+
+	newLogger(&buf, 2).Debug("details")
+	require.Contains(t, buf.String(), "level=DEBUG msg=details")
+}
+
 // -------------------------------------------- Test Helper Functions --------------------------------------------
 
 // createTestFile creates a temporary file with the given data for testing.
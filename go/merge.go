@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// mergeIntermediatesCommand is the subcommand that merges intermediate files, the reduce
+// step after every node of a distributed run wrote its own with -append-output.
+const mergeIntermediatesCommand = "merge-intermediates"
+
+// runMergeIntermediates implements `merge-intermediates out.txt in1.txt in2.txt ...`: it
+// merges the intermediate files exactly and writes the text output to out.txt, or to
+// stdout when it is "-".
+func runMergeIntermediates(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet(mergeIntermediatesCommand, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return errors.New("usage: merge-intermediates out.txt in1.txt [in2.txt ...]")
+	}
+
+	merged := make(Result)
+	for _, path := range fs.Args()[1:] {
+		result, err := readIntermediateFile(path)
+		if err != nil {
+			return &inputError{path: path, err: err}
+		}
+		merged.Merge(result)
+	}
+
+	output := formatOutput(merged.ToMap()) + "\n"
+
+	if out := fs.Arg(0); out != stdinPath {
+		if err := os.WriteFile(out, []byte(output), 0o644); err != nil {
+			return fmt.Errorf("could not write output file: %w", err)
+		}
+		return nil
+	}
+	_, err := io.WriteString(stdout, output)
+	return err
+}
+
+// readIntermediateFile reads the intermediate file at path.
+func readIntermediateFile(path string) (Result, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open intermediate: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	return ReadIntermediate(file)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_MergeIntermediates tests that merging the intermediates of three shards gives the
+// output of processing the concatenated raw input.
+func TestRun_MergeIntermediates(t *testing.T) {
+	shards := []string{
+		"Hamburg;12.0\nBerlin;20.0\n",
+		"Hamburg;8.0\nOslo;-5.0\nBerlin;25.0\n",
+		"Oslo;-1.5\nTokyo;30.5\n",
+	}
+	dir := t.TempDir()
+	var inputs []string
+	for i, shard := range shards {
+		file := createTestFile(t, shard)
+		defer cleanupTestFile(t, file)
+		intermediate := filepath.Join(dir, fmt.Sprintf("shard%d.txt", i))
+		require.NoError(t, run([]string{"-append-output", intermediate, file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{}))
+		inputs = append(inputs, intermediate)
+	}
+
+	whole := createTestFile(t, strings.Join(shards, ""))
+	defer cleanupTestFile(t, whole)
+	var want bytes.Buffer
+	require.NoError(t, run([]string{whole.Name()}, nil, &want, &bytes.Buffer{}))
+
+	out := filepath.Join(dir, "out.txt")
+	require.NoError(t, run(append([]string{mergeIntermediatesCommand, out}, inputs...), nil, &bytes.Buffer{}, &bytes.Buffer{}))
+	got, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Equal(t, strings.TrimSuffix(want.String(), "\n"), string(got))
+
+	var stdout bytes.Buffer
+	require.NoError(t, run(append([]string{mergeIntermediatesCommand, "-"}, inputs...), nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, string(got), stdout.String())
+
+	err = run([]string{mergeIntermediatesCommand, out}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "usage: merge-intermediates")
+	err = run([]string{mergeIntermediatesCommand, out, filepath.Join(dir, "missing.txt")}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "could not open intermediate")
+}
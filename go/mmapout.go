@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// mmapBytesPerStation is the output size reserved per station before the first write, enough
+// for a typical line of most formats, so small and medium results never have to grow the mapping.
+const mmapBytesPerStation = 64
+
+// mmapWriter is an io.Writer writing straight into a shared, writable memory mapping of its
+// file: the output side of mmapFile. The file is grown, doubling its size, and remapped when
+// a write doesn't fit, and truncated to the bytes actually written by Close.
+type mmapWriter struct {
+	file *os.File
+	data []byte // the mapping, len(data) is the current file size
+	n    int    // bytes written so far
+}
+
+// newMmapWriter creates the file at path, pre-sized to size bytes and mapped for writing.
+func newMmapWriter(path string, size int) (*mmapWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not create output file: %w", err)
+	}
+	w := &mmapWriter{file: file}
+	if err = w.grow(max(size, os.Getpagesize())); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write implements io.Writer.
+func (w *mmapWriter) Write(b []byte) (int, error) {
+	if w.n+len(b) > len(w.data) {
+		if err := w.grow(max(2*len(w.data), w.n+len(b))); err != nil {
+			return 0, err
+		}
+	}
+	w.n += copy(w.data[w.n:], b)
+	return len(b), nil
+}
+
+// grow resizes the file to size bytes and maps it again, as a mapping can't outgrow the
+// file behind it.
+func (w *mmapWriter) grow(size int) error {
+	if err := w.unmap(); err != nil {
+		return err
+	}
+	if err := w.file.Truncate(int64(size)); err != nil {
+		return fmt.Errorf("could not resize output file: %w", err)
+	}
+	data, err := syscall.Mmap(int(w.file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("could not memory map output file: %w", err)
+	}
+	w.data = data
+	return nil
+}
+
+// unmap releases the current mapping, if any. Its pages are shared with the file, so the
+// written bytes stay in it.
+func (w *mmapWriter) unmap() error {
+	if w.data == nil {
+		return nil
+	}
+	err := syscall.Munmap(w.data)
+	w.data = nil
+	if err != nil {
+		return fmt.Errorf("could not unmap output file: %w", err)
+	}
+	return nil
+}
+
+// Close unmaps the file, cuts off the unused tail of the last growth and closes it.
+func (w *mmapWriter) Close() error {
+	err := w.unmap()
+	if truncErr := w.file.Truncate(int64(w.n)); truncErr != nil && err == nil {
+		err = fmt.Errorf("could not resize output file: %w", truncErr)
+	}
+	if closeErr := w.file.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("could not close output file: %w", closeErr)
+	}
+	return err
+}
+
+// writeFormatFileMmap is writeFormatFile through an mmapWriter, sized for the result up front.
+func writeFormatFileMmap(path string, fn FormatFunc, result Result) (err error) {
+	w, err := newMmapWriter(path, len(result)*mmapBytesPerStation)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := w.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}()
+	return fn(w, result)
+}
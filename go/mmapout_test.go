@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestMmapWriter tests that writes land in the file, that the mapping grows past its initial
+// size, and that Close trims the file to the bytes written.
+func TestMmapWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.txt")
+	w, err := newMmapWriter(path, 0)
+	require.NoError(t, err)
+	initial := len(w.data)
+
+	var want strings.Builder
+	for i := range 3 * initial / 16 {
+		line := []byte(strings.Repeat(string(rune('a'+i%26)), 15) + "\n")
+		n, err := w.Write(line)
+		require.NoError(t, err)
+		require.Equal(t, len(line), n)
+		want.Write(line)
+	}
+	require.Greater(t, len(w.data), initial, "the mapping grew")
+	require.NoError(t, w.Close())
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, want.String(), string(got))
+}
+
+// TestWriteFormatFileMmap tests writing a small result through the mmap writer and reading
+// it back, including an empty result.
+func TestWriteFormatFileMmap(t *testing.T) {
+	stats := map[string][4]float64{
+		"Hamburg": {8.0, 20.0, 2.0, 12.0},
+		"Berlin":  {20.0, 45.0, 2.0, 25.0},
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.msgpack")
+	require.NoError(t, writeFormatFileMmap(path, writeMsgpack, FromMap(stats)))
+
+	var want bytes.Buffer
+	require.NoError(t, writeMsgpack(&want, FromMap(stats)))
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, want.Bytes(), got, "identical to the buffered output")
+
+	empty := filepath.Join(dir, "empty.txt")
+	require.NoError(t, writeFormatFileMmap(empty, func(w io.Writer, _ Result) error { return nil }, Result{}))
+	info, err := os.Stat(empty)
+	require.NoError(t, err)
+	require.Zero(t, info.Size())
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_MmapOutput tests that -mmap-output writes the -o and -out files.
+func TestRun_MmapOutput(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\n")
+	defer cleanupTestFile(t, file)
+	dir := t.TempDir()
+	msgpackPath, textPath := filepath.Join(dir, "out.msgpack"), filepath.Join(dir, "out.txt")
+
+	args := []string{"-mmap-output", "-format", "msgpack", "-o", msgpackPath, "-out", "text=" + textPath, file.Name()}
+	require.NoError(t, run(args, nil, &bytes.Buffer{}, &bytes.Buffer{}))
+
+	text, err := os.ReadFile(textPath)
+	require.NoError(t, err)
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n", string(text))
+
+	data, err := os.ReadFile(msgpackPath)
+	require.NoError(t, err)
+	var decoded map[string][4]float64
+	require.NoError(t, msgpack.Unmarshal(data, &decoded))
+	require.Equal(t, map[string][4]float64{
+		"Berlin":  {20.0, 20.0, 20.0, 1},
+		"Hamburg": {8.0, 10.0, 12.0, 2},
+	}, decoded)
+
+	err = run([]string{"-mmap-output", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-mmap-output requires -out")
+}
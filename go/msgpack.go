@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// writeMsgpack encodes the result as a MessagePack map from station to
+// [min, mean, max, count]. Entries are written in name order, so the output is deterministic.
+func writeMsgpack(w io.Writer, result Result) error {
+	enc := msgpack.NewEncoder(w)
+	if err := enc.EncodeMapLen(len(result)); err != nil {
+		return fmt.Errorf("could not encode msgpack: %w", err)
+	}
+	for _, station := range slices.Sorted(maps.Keys(result)) {
+		s := result[station]
+		if err := enc.EncodeString(station); err != nil {
+			return fmt.Errorf("could not encode msgpack: %w", err)
+		}
+		if err := enc.Encode([]any{s.Min, s.Mean(), s.Max, s.Count}); err != nil {
+			return fmt.Errorf("could not encode msgpack: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestWriteMsgpack_RoundTrip tests that the encoded map decodes back to the same values,
+// and that encoding is deterministic.
+func TestWriteMsgpack_RoundTrip(t *testing.T) {
+	stats := map[string][4]float64{
+		"Hamburg": {8.0, 20.0, 2.0, 12.0},
+		"Berlin":  {20.0, 45.0, 2.0, 25.0},
+		"東京":      {-3.5, -3.5, 1.0, -3.5},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeMsgpack(&buf, FromMap(stats)))
+
+	var decoded map[string][]any
+	require.NoError(t, msgpack.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, map[string][]any{
+		"Berlin":  {20.0, 22.5, 25.0, int64(2)},
+		"Hamburg": {8.0, 10.0, 12.0, int64(2)},
+		"東京":      {-3.5, -3.5, -3.5, int64(1)},
+	}, decoded)
+
+	for range 10 {
+		var again bytes.Buffer
+		require.NoError(t, writeMsgpack(&again, FromMap(stats)))
+		require.Equal(t, buf.Bytes(), again.Bytes())
+	}
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_Msgpack tests -format msgpack -o.
+func TestRun_Msgpack(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\n")
+	defer cleanupTestFile(t, file)
+	path := filepath.Join(t.TempDir(), "out.msgpack")
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-format", "msgpack", "-o", path, file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Empty(t, stdout.String())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var decoded map[string][4]float64
+	require.NoError(t, msgpack.Unmarshal(data, &decoded))
+	require.Equal(t, map[string][4]float64{
+		"Berlin":  {20.0, 20.0, 20.0, 1},
+		"Hamburg": {8.0, 10.0, 12.0, 2},
+	}, decoded)
+
+	err = run([]string{"-format", "msgpack", file.Name()}, nil, &stdout, &bytes.Buffer{})
+	require.EqualError(t, err, "-format msgpack requires -o")
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// splitTimestamp splits the trailing unix timestamp off a `station;temp;unixSeconds` line,
+// with sep as the separator, returning the remaining `station;temp` part.
+func splitTimestamp(line string, sep byte) (string, int64, error) {
+	lastSep := strings.LastIndexByte(line, sep)
+	if lastSep == -1 {
+		return "", 0, fmt.Errorf("could not parse timestamp in line: %s", line)
+	}
+	seconds, err := strconv.ParseInt(line[lastSep+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("could not parse timestamp: %w", err)
+	}
+	return line[:lastSep], seconds, nil
+}
+
+// observeOrder records the timestamp of a station's reading for -check-order, counting it
+// as out of order when it is earlier than the latest timestamp seen for the station.
+// Equal timestamps are in order.
+func (p *processor) observeOrder(station string, seconds int64) {
+	last, seen := p.lastSeen[station]
+	if seen && seconds < last {
+		p.outOfOrder++
+		return // keep the latest timestamp, so each late reading is counted once
+	}
+	p.lastSeen[station] = seconds
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestSplitTimestamp tests splitting the trailing timestamp off a line.
+func TestSplitTimestamp(t *testing.T) {
+	rest, seconds, err := splitTimestamp("Berlin;12.0;1700000000", ';')
+	require.NoError(t, err)
+	require.Equal(t, "Berlin;12.0", rest)
+	require.Equal(t, int64(1700000000), seconds)
+
+	_, _, err = splitTimestamp("Berlin;12.0;yesterday", ';')
+	require.ErrorContains(t, err, "could not parse timestamp")
+}
+
+// TestProcessLine_CheckOrder tests that readings older than their station's latest
+// timestamp are counted, independently per station.
+func TestProcessLine_CheckOrder(t *testing.T) {
+	p := newProcessor(options{checkOrder: true})
+	for _, line := range []string{
+		"Berlin;10.0;100",
+		"Oslo;-5.0;50",
+		"Berlin;12.0;300",
+		"Berlin;11.0;200", // older than 300
+		"Oslo;-4.0;60",
+		"Berlin;13.0;150", // still older than 300
+		"Berlin;14.0;300", // equal is in order
+		"Berlin;15.0;400",
+	} {
+		require.NoError(t, p.processLine(line))
+	}
+
+	require.Equal(t, int64(2), p.outOfOrder)
+	require.Equal(t, map[string]int64{"Berlin": 400, "Oslo": 60}, p.lastSeen)
+	require.Equal(t, [4]float64{10.0, 75.0, 6, 15.0}, p.stats["Berlin"])
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_CheckOrder tests that -check-order reports the out-of-order count on stderr.
+func TestRun_CheckOrder(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0;1000\nHamburg;8.0;3000\nBerlin;20.0;2000\nHamburg;10.0;2000\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"-check-order", file.Name()}, nil, &stdout, &stderr))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n\n", stdout.String())
+	require.Equal(t, "found 1 out-of-order readings\n", stderr.String())
+
+	err := run([]string{"-check-order", "-by-hour", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-check-order can't be combined with")
+}
+
+// TestRun_CheckOrderTee tests that -tee copies the lines with their timestamps.
+func TestRun_CheckOrderTee(t *testing.T) {
+	input := "Hamburg;12.0;1000\nBerlin;20.0;2000\nHamburg;8.0;3000\n"
+	file := createTestFile(t, input)
+	defer cleanupTestFile(t, file)
+	tee := filepath.Join(t.TempDir(), "clean.txt")
+
+	require.NoError(t, run([]string{"-check-order", "-tee", tee, file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{}))
+	data, err := os.ReadFile(tee)
+	require.NoError(t, err)
+	require.Equal(t, input, string(data))
+}
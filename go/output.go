@@ -0,0 +1,315 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Output formats selectable with -format.
+const (
+	formatText    = "text"    // the 1BRC `{station=min/mean/max, ...}` line
+	formatTable   = "table"   // an aligned table, one station per row
+	formatSQLite  = "sqlite"  // a `stations` table in the SQLite database given by -o
+	formatMsgpack = "msgpack" // a MessagePack map of station to [min, mean, max, count], written to -o
+)
+
+// outputOptions controls how the aggregated stats are rendered.
+// The zero value renders stations sorted by name with no extra fields.
+type outputOptions struct {
+	sortKey     SortKey
+	sortDesc    bool                        // reverse the order selected by sortKey
+	only        SortKey                     // text format: print just this metric (min, mean or max), empty for all
+	color       bool                        // table format: colorize min and max
+	annotate    func(station string) string // extra fields appended to a station, may be nil
+	fieldSep    string                      // text format: separator between min, mean and max, "/" when empty
+	entrySep    string                      // text format: separator between stations, ", " when empty
+	decimals    int                         // text and table formats: digits after the decimal point when decimalsSet
+	decimalsSet bool                        // decimals was set (to 0 for integer data too), otherwise 1 digit is printed
+	collator    *collate.Collator           // orders station names by a locale's rules instead of byte-wise, may be nil
+	truncate    int                         // text and table formats: cut names to this many runes with an ellipsis (0 = full names)
+	mmap        bool                        // -o and -out files: write through a shared memory mapping, see mmapWriter
+}
+
+// displayName returns name as printed: cut to out.truncate runes, the last of them an
+// ellipsis, when it is longer. Cutting at rune boundaries keeps multibyte names valid UTF-8.
+func (out outputOptions) displayName(name string) string {
+	if out.truncate == 0 || utf8.RuneCountInString(name) <= out.truncate {
+		return name
+	}
+	runes := 0
+	for i := range name {
+		if runes == out.truncate-1 {
+			return name[:i] + "…"
+		}
+		runes++
+	}
+	return name
+}
+
+// fieldSeparator returns the configured separator between an entry's values, defaulting to "/".
+func (out outputOptions) fieldSeparator() string {
+	if out.fieldSep == "" {
+		return "/"
+	}
+	return out.fieldSep
+}
+
+// entrySeparator returns the configured separator between entries, defaulting to ", ".
+func (out outputOptions) entrySeparator() string {
+	if out.entrySep == "" {
+		return ", "
+	}
+	return out.entrySep
+}
+
+// precision returns the configured number of decimal places, defaulting to 1.
+func (out outputOptions) precision() int {
+	if !out.decimalsSet {
+		return 1
+	}
+	return out.decimals
+}
+
+// validateOutputSeparator checks that a -field-sep or -entry-sep value can't be mistaken
+// for part of a station's values.
+func validateOutputSeparator(name, sep string) error {
+	if sep == "" || strings.ContainsAny(sep, "0123456789.-={}") {
+		return fmt.Errorf("-%s must be non-empty and can't contain digits or any of .-={}, got %q", name, sep)
+	}
+	return nil
+}
+
+// SortKey selects the field stations are ordered by in the output.
+type SortKey string
+
+// Supported sort keys. SortByName is the 1BRC order and the default.
+const (
+	SortByName  SortKey = "name"
+	SortByMean  SortKey = "mean"
+	SortByMin   SortKey = "min"
+	SortByMax   SortKey = "max"
+	SortByCount SortKey = "count"
+)
+
+// parseSortKey validates a -sort flag value. An empty value selects SortByName.
+func parseSortKey(value string) (SortKey, error) {
+	switch key := SortKey(value); key {
+	case "":
+		return SortByName, nil
+	case SortByName, SortByMean, SortByMin, SortByMax, SortByCount:
+		return key, nil
+	default:
+		return "", fmt.Errorf("unknown sort key %q", value)
+	}
+}
+
+// stations summarizes the stats and orders them by out.sortKey, reversed when out.sortDesc
+// is set. Stations tied on a metric stay ordered by name ascending in both directions, so
+// the output is deterministic.
+func (out outputOptions) stations(stats map[string][4]float64) []StationStat {
+	stations := SortedStats(stats)
+	slices.SortFunc(stations, out.compare)
+	return stations
+}
+
+// compare orders two stations for output: compareStations, or with out.sortDesc the
+// reversed metric order, still breaking ties by name ascending. With out.collator set,
+// names are compared by its locale's rules, and byte-wise only when it deems them equal.
+func (out outputOptions) compare(a, b StationStat) int {
+	first, second := a, b
+	if out.sortDesc {
+		first, second = b, a
+	}
+
+	var c int
+	if out.collator != nil && (out.sortKey == SortByName || out.sortKey == "") {
+		c = out.collator.CompareString(first.Name, second.Name)
+	} else {
+		c = compareMetric(first, second, out.sortKey)
+	}
+	if c != 0 {
+		return c
+	}
+	if out.collator != nil {
+		if c = out.collator.CompareString(a.Name, b.Name); c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(a.Name, b.Name)
+}
+
+// newCollator returns a collator for the -collate language tag, such as "de" or "sv".
+func newCollator(tag string) (*collate.Collator, error) {
+	lang, err := language.Parse(tag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -collate locale %q: %w", tag, err)
+	}
+	return collate.New(lang), nil
+}
+
+// compareStations orders stations by key, ascending, breaking ties by name. It is a total
+// order over stations with distinct names, which keeps every sorted output deterministic.
+func compareStations(a, b StationStat, key SortKey) int {
+	if c := compareMetric(a, b, key); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Name, b.Name)
+}
+
+// compareMetric compares the field selected by key, with SortByName (or an empty key)
+// comparing names byte-wise.
+func compareMetric(a, b StationStat, key SortKey) int {
+	switch key {
+	case SortByMean:
+		return cmp.Compare(a.Mean, b.Mean)
+	case SortByMin:
+		return cmp.Compare(a.Min, b.Min)
+	case SortByMax:
+		return cmp.Compare(a.Max, b.Max)
+	case SortByCount:
+		return cmp.Compare(a.Count, b.Count)
+	default: // SortByName
+		return strings.Compare(a.Name, b.Name)
+	}
+}
+
+// values formats the metrics of a text format entry: `min/mean/max` with out's field
+// separator, or only the one selected by out.only. A station without readings, seeded by
+// -emit-empty, shows `-` for every metric.
+func (out outputOptions) values(s StationStat) string {
+	sep, decimals := out.fieldSeparator(), out.precision()
+	if s.Count == 0 {
+		if out.only != "" {
+			return "-"
+		}
+		return "-" + sep + "-" + sep + "-"
+	}
+	switch out.only {
+	case SortByMin:
+		return fmt.Sprintf("%.*f", decimals, s.Min)
+	case SortByMean:
+		return fmt.Sprintf("%.*f", decimals, s.Mean)
+	case SortByMax:
+		return fmt.Sprintf("%.*f", decimals, s.Max)
+	default:
+		return fmt.Sprintf("%.*f%s%.*f%s%.*f", decimals, s.Min, sep, decimals, s.Mean, sep, decimals, s.Max)
+	}
+}
+
+// outputEntry matches one `station=min/mean/max` (or single metric) entry of the text format,
+// or its `-/-/-` placeholder, with optional annotations after a space. The station is the shortest prefix followed by valid values.
+var outputEntry = regexp.MustCompile(`^(.*?)=(?:-?\d+(?:\.\d+)?(?:/-?\d+(?:\.\d+)?/-?\d+(?:\.\d+)?)?|-(?:/-/-)?)(?: .*)?$`)
+
+// validateSorted parses text format output and checks that the station keys are in the
+// exact byte-wise order sort.Strings produces, which is what the 1BRC reference expects.
+// It catches ordering regressions from custom formatters.
+func validateSorted(output string) error {
+	if !strings.HasPrefix(output, "{") || !strings.HasSuffix(output, "}") {
+		return errors.New("output is not enclosed in braces")
+	}
+	body := output[1 : len(output)-1]
+	if body == "" {
+		return nil
+	}
+
+	var previous string
+	for i, entry := range strings.Split(body, ", ") {
+		match := outputEntry.FindStringSubmatch(entry)
+		if match == nil {
+			return fmt.Errorf("could not parse output entry %q", entry)
+		}
+
+		station := match[1]
+		if i > 0 && station <= previous {
+			return fmt.Errorf("station %q is out of order after %q", station, previous)
+		}
+		previous = station
+	}
+
+	return nil
+}
+
+// ANSI escape sequences used by the colored table output.
+const (
+	ansiReset = "\x1b[0m"
+	ansiBlue  = "\x1b[34m"
+	ansiRed   = "\x1b[31m"
+)
+
+// formatTableOutput formats the statistics as an aligned table with one station per row,
+// ordered and annotated according to out. With out.color set, min values are printed in
+// blue and max values in red.
+func formatTableOutput(stats map[string][4]float64, out outputOptions) string {
+	stations := out.stations(stats)
+
+	nameWidth := utf8.RuneCountInString("station")
+	for _, station := range stations {
+		nameWidth = max(nameWidth, utf8.RuneCountInString(out.displayName(station.Name)))
+	}
+
+	paint := func(value, code string) string {
+		if !out.color {
+			return value
+		}
+		return code + value + ansiReset
+	}
+
+	decimals := out.precision()
+	var output strings.Builder
+	fmt.Fprintf(&output, "%s  %6s  %6s  %6s\n", padRight("station", nameWidth), "min", "mean", "max")
+	for _, station := range stations {
+		fmt.Fprintf(&output, "%s  %s  %6.*f  %s",
+			padRight(out.displayName(station.Name), nameWidth),
+			paint(fmt.Sprintf("%6.*f", decimals, station.Min), ansiBlue),
+			decimals, station.Mean,
+			paint(fmt.Sprintf("%6.*f", decimals, station.Max), ansiRed),
+		)
+		if out.annotate != nil {
+			output.WriteString(out.annotate(station.Name))
+		}
+		output.WriteString("\n")
+	}
+
+	return output.String()
+}
+
+// padRight pads s with spaces to width runes. fmt's width verbs count bytes, which
+// misaligns multibyte station names.
+func padRight(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// useColor reports whether colored output should be emitted: it has to be requested,
+// not vetoed through the NO_COLOR environment variable, and stdout has to be a terminal.
+func useColor(requested bool, stdout io.Writer) bool {
+	if !requested || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminalWriter(stdout)
+}
+
+// isTerminalWriter reports whether w is a file attached to a terminal.
+func isTerminalWriter(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	return ok && isTerminal(file)
+}
+
+// isTerminal reports whether file is attached to a terminal. Other character devices, such as
+// /dev/null, are not terminals.
+func isTerminal(file *os.File) bool {
+	return term.IsTerminal(int(file.Fd()))
+}
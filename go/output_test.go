@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestFormatTableOutput tests the aligned table layout without color.
+func TestFormatTableOutput(t *testing.T) {
+	stats := map[string][4]float64{
+		"Hamburg": {8.0, 20.0, 2.0, 12.0},
+		"北京":      {-3.7, -3.7, 1.0, -3.7},
+	}
+
+	expected := "" +
+		"station     min    mean     max\n" +
+		"Hamburg     8.0    10.0    12.0\n" +
+		"北京         -3.7    -3.7    -3.7\n"
+	output := formatTableOutput(stats, outputOptions{})
+	require.Equal(t, expected, output)
+	require.NotContains(t, output, "\x1b[")
+}
+
+// TestFormatTableOutput_Color tests that forced color wraps min in blue and max in red.
+func TestFormatTableOutput_Color(t *testing.T) {
+	stats := map[string][4]float64{"Hamburg": {8.0, 20.0, 2.0, 12.0}}
+
+	output := formatTableOutput(stats, outputOptions{color: true})
+	require.Contains(t, output, ansiBlue+"   8.0"+ansiReset)
+	require.Contains(t, output, ansiRed+"  12.0"+ansiReset)
+}
+
+// sortFixture has a tie on max (Berlin and Copenhagen) to exercise the tie-break.
+var sortFixture = map[string][4]float64{
+	"Hamburg":    {5.0, 30.0, 3.0, 15.0},  // mean 10.0
+	"Berlin":     {10.0, 45.0, 3.0, 20.0}, // mean 15.0
+	"Copenhagen": {0.0, 10.0, 4.0, 20.0},  // mean 2.5
+}
+
+// TestFormatOutputWith_Sort tests -sort with and without -sort-desc.
+func TestFormatOutputWith_Sort(t *testing.T) {
+	tests := []struct {
+		name     string
+		out      outputOptions
+		expected string
+	}{
+		{"name", outputOptions{sortKey: SortByName},
+			"{Berlin=10.0/15.0/20.0, Copenhagen=0.0/2.5/20.0, Hamburg=5.0/10.0/15.0}"},
+		{"name desc", outputOptions{sortKey: SortByName, sortDesc: true},
+			"{Hamburg=5.0/10.0/15.0, Copenhagen=0.0/2.5/20.0, Berlin=10.0/15.0/20.0}"},
+		{"mean", outputOptions{sortKey: SortByMean},
+			"{Copenhagen=0.0/2.5/20.0, Hamburg=5.0/10.0/15.0, Berlin=10.0/15.0/20.0}"},
+		{"mean desc", outputOptions{sortKey: SortByMean, sortDesc: true},
+			"{Berlin=10.0/15.0/20.0, Hamburg=5.0/10.0/15.0, Copenhagen=0.0/2.5/20.0}"},
+		{"max desc ties by name", outputOptions{sortKey: SortByMax, sortDesc: true},
+			"{Berlin=10.0/15.0/20.0, Copenhagen=0.0/2.5/20.0, Hamburg=5.0/10.0/15.0}"},
+		{"count", outputOptions{sortKey: SortByCount},
+			"{Berlin=10.0/15.0/20.0, Hamburg=5.0/10.0/15.0, Copenhagen=0.0/2.5/20.0}"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, formatOutputWith(sortFixture, tc.out))
+		})
+	}
+}
+
+// TestCompareStations_TotalOrder is a property test: for every sort key and direction, the
+// output comparison is a total order over randomized stations with frequent metric ties,
+// so sorting is deterministic whatever the input order.
+func TestCompareStations_TotalOrder(t *testing.T) {
+	rng := newRand(687)
+	stations := make([]StationStat, 40)
+	for i := range stations {
+		stations[i] = StationStat{
+			Name:  fmt.Sprintf("s%03d", rng.IntN(1000)*1000+i), // unique, in random order
+			Min:   float64(rng.IntN(3)),
+			Mean:  float64(rng.IntN(3)) / 2,
+			Max:   float64(rng.IntN(3)),
+			Count: int64(rng.IntN(3)),
+		}
+	}
+
+	for _, key := range []SortKey{SortByName, SortByMean, SortByMin, SortByMax, SortByCount} {
+		for _, desc := range []bool{false, true} {
+			out := outputOptions{sortKey: key, sortDesc: desc}
+			t.Run(fmt.Sprintf("%s desc=%t", key, desc), func(t *testing.T) {
+				for _, a := range stations {
+					for _, b := range stations {
+						ab, ba := out.compare(a, b), out.compare(b, a)
+						require.Equal(t, sign(ab), -sign(ba), "antisymmetric: %v %v", a, b)
+						require.Equal(t, a.Name == b.Name, ab == 0, "only equal to itself: %v %v", a, b)
+						for _, c := range stations {
+							if ab < 0 && out.compare(b, c) < 0 {
+								require.Negative(t, out.compare(a, c), "transitive: %v %v %v", a, b, c)
+							}
+						}
+					}
+				}
+
+				sorted := slices.Clone(stations)
+				slices.SortFunc(sorted, out.compare)
+				for range 5 {
+					shuffled := slices.Clone(stations)
+					rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+					slices.SortFunc(shuffled, out.compare)
+					require.Equal(t, sorted, shuffled)
+				}
+			})
+		}
+	}
+}
+
+// TestParseSortKey tests validation of -sort values.
+func TestParseSortKey(t *testing.T) {
+	key, err := parseSortKey("")
+	require.NoError(t, err)
+	require.Equal(t, SortByName, key)
+
+	key, err = parseSortKey("count")
+	require.NoError(t, err)
+	require.Equal(t, SortByCount, key)
+
+	_, err = parseSortKey("median")
+	require.EqualError(t, err, `unknown sort key "median"`)
+}
+
+// TestFormatOutputWith_Only tests each single-metric projection.
+func TestFormatOutputWith_Only(t *testing.T) {
+	stats := map[string][4]float64{
+		"Hamburg": {8.0, 20.0, 2.0, 12.0},
+		"Berlin":  {20.0, 45.0, 2.0, 25.0},
+	}
+
+	tests := map[SortKey]string{
+		SortByMin:  "{Berlin=20.0, Hamburg=8.0}",
+		SortByMean: "{Berlin=22.5, Hamburg=10.0}",
+		SortByMax:  "{Berlin=25.0, Hamburg=12.0}",
+	}
+	for only, expected := range tests {
+		t.Run(string(only), func(t *testing.T) {
+			output := formatOutputWith(stats, outputOptions{only: only})
+			require.Equal(t, expected, output)
+			require.NoError(t, validateSorted(output))
+		})
+	}
+}
+
+// TestValidateSorted tests validation of correctly and incorrectly ordered output.
+func TestValidateSorted(t *testing.T) {
+	valid := []string{
+		"{}",
+		"{Berlin=20.0/22.5/25.0}",
+		"{Berlin=20.0/22.5/25.0, Hamburg=8.0/10.0/12.0, Zürich=-1.0/0.0/1.0, Ärhus=1.0/1.0/1.0}",
+		"{New York=1.0/2.0/3.0 distinct=3, São Paulo=-5.0/-2.5/0.0 distinct=2}",
+		formatOutput(sortFixture),
+	}
+	for _, output := range valid {
+		require.NoError(t, validateSorted(output), output)
+	}
+
+	err := validateSorted("{Hamburg=8.0/10.0/12.0, Berlin=20.0/22.5/25.0}")
+	require.EqualError(t, err, `station "Berlin" is out of order after "Hamburg"`)
+
+	err = validateSorted("{Ärhus=1.0/1.0/1.0, Zürich=-1.0/0.0/1.0}") // locale order, not byte order
+	require.EqualError(t, err, `station "Zürich" is out of order after "Ärhus"`)
+
+	err = validateSorted("{Berlin=20.0/22.5/25.0, Berlin=1.0/1.0/1.0}")
+	require.Error(t, err, "duplicates are out of order too")
+
+	require.EqualError(t, validateSorted("Berlin=20.0/22.5/25.0"), "output is not enclosed in braces")
+	require.EqualError(t, validateSorted("{Berlin=warm}"), `could not parse output entry "Berlin=warm"`)
+}
+
+// TestUseColor tests that color is only used when requested, allowed and on a terminal.
+func TestUseColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	require.False(t, useColor(false, &bytes.Buffer{}))
+	require.False(t, useColor(true, &bytes.Buffer{})) // not a terminal
+
+	file := createTestFile(t, "")
+	defer cleanupTestFile(t, file)
+	require.False(t, useColor(true, file)) // regular file, not a terminal
+
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	defer func() { _ = devNull.Close() }()
+	require.False(t, useColor(true, devNull)) // character device, not a terminal
+
+	t.Setenv("NO_COLOR", "1")
+	require.False(t, useColor(true, file))
+}
+
+// TestFormatOutputWith_Separators tests custom field and entry separators.
+func TestFormatOutputWith_Separators(t *testing.T) {
+	stats := map[string][4]float64{
+		"Hamburg": {8.0, 20.0, 2.0, 12.0},
+		"Berlin":  {20.0, 45.0, 2.0, 25.0},
+	}
+
+	out := outputOptions{fieldSep: "|", entrySep: ";"}
+	require.Equal(t, "{Berlin=20.0|22.5|25.0;Hamburg=8.0|10.0|12.0}", formatOutputWith(stats, out))
+	stats["Oslo"] = [4]float64{}
+	require.Equal(t, "{Berlin=20.0|22.5|25.0;Hamburg=8.0|10.0|12.0;Oslo=-|-|-}", formatOutputWith(stats, out))
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_FormatTableColorPiped tests that -color emits no escapes when stdout isn't a terminal.
+func TestRun_FormatTableColorPiped(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-format", "table", "-color", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.True(t, strings.HasPrefix(stdout.String(), "station"))
+	require.NotContains(t, stdout.String(), "\x1b[")
+
+	err := run([]string{"-format", "yaml", file.Name()}, nil, &stdout, &bytes.Buffer{})
+	require.EqualError(t, err, `unknown output format "yaml"`)
+}
+
+// TestRun_ValidateSorted tests that -validate-sorted rejects a non 1BRC order.
+func TestRun_ValidateSorted(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\n")
+	defer cleanupTestFile(t, file)
+
+	require.NoError(t, run([]string{"-validate-sorted", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{}))
+
+	err := run([]string{"-validate-sorted", "-sort-desc", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "output failed sort validation")
+}
+
+// TestRun_OutputBuffer tests that output larger than the buffer is written completely.
+func TestRun_OutputBuffer(t *testing.T) {
+	var data strings.Builder
+	stats := make(map[string][4]float64)
+	for i := 0; i < 500; i++ {
+		line := fmt.Sprintf("Station%03d;%d.5", i, i%100)
+		data.WriteString(line + "\n")
+		require.NoError(t, processLine(line, stats))
+	}
+	file := createTestFile(t, data.String())
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-output-buffer", "16", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, formatOutput(stats)+"\n\n", stdout.String())
+}
+
+// TestRun_OutputBufferFlushedOnError tests that output written before a failure is flushed.
+func TestRun_OutputBufferFlushedOnError(t *testing.T) {
+	var stdout bytes.Buffer
+	stdin := strings.NewReader("Berlin;1.0\nHamburg;2.0\nAachen;3.0\n")
+
+	err := run([]string{"-sorted-input", "-"}, stdin, &stdout, &bytes.Buffer{})
+	require.Error(t, err)
+	require.Equal(t, "{Berlin=1.0/1.0/1.0", stdout.String())
+}
+
+// TestRun_SortDesc tests -sort and -sort-desc from the command line.
+func TestRun_SortDesc(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nOslo;-5.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-sort", "mean", "-sort-desc", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=12.0/12.0/12.0, Oslo=-5.0/-5.0/-5.0}\n\n", stdout.String())
+}
+
+// TestRun_OnlyExclusive tests that at most one of -min-only, -mean-only and -max-only is accepted,
+// and only with the text format.
+func TestRun_OnlyExclusive(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nHamburg;8.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-max-only", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Hamburg=12.0}\n\n", stdout.String())
+
+	err := run([]string{"-min-only", "-max-only", file.Name()}, nil, &stdout, &bytes.Buffer{})
+	require.EqualError(t, err, "-min-only, -mean-only and -max-only are mutually exclusive")
+
+	for _, args := range [][]string{{"-format", "table"}, {"-out", "table=" + filepath.Join(t.TempDir(), "out.txt")}} {
+		err = run(append(append([]string{"-min-only"}, args...), file.Name()), nil, &bytes.Buffer{}, &bytes.Buffer{})
+		require.EqualError(t, err, "-min-only, -mean-only and -max-only only apply to the text format, including -out targets", args)
+	}
+}
+
+// -------------------------------------------- Test Helper Functions --------------------------------------------
+
+// sign returns -1, 0 or 1 according to the sign of c.
+func sign(c int) int {
+	return cmp.Compare(c, 0)
+}
+
+// TestRun_OutputSeparators tests -field-sep and -entry-sep and their validation.
+func TestRun_OutputSeparators(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\nBerlin;25.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-field-sep", "|", "-entry-sep", ";", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.0|22.5|25.0;Hamburg=8.0|10.0|12.0}\n\n", stdout.String())
+
+	for _, args := range [][]string{
+		{"-field-sep", "."},
+		{"-entry-sep", "="},
+		{"-field-sep", ";", "-entry-sep", ";"},
+		{"-field-sep", "|", "-format", "table"},
+	} {
+		require.Error(t, run(append(args, file.Name()), nil, &bytes.Buffer{}, &bytes.Buffer{}), "%v", args)
+	}
+}
+
+// TestRun_Collate tests that -collate orders accented names by the locale's rules, which
+// differ between locales, while the default stays byte-wise.
+func TestRun_Collate(t *testing.T) {
+	file := createTestFile(t, "Zurich;1.0\nÄrhus;2.0\nBerlin;3.0\nAalborg;4.0\n")
+	defer cleanupTestFile(t, file)
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, "{Aalborg=4.0/4.0/4.0, Berlin=3.0/3.0/3.0, Zurich=1.0/1.0/1.0, Ärhus=2.0/2.0/2.0}\n\n"},
+		{[]string{"-collate", "de"}, "{Aalborg=4.0/4.0/4.0, Ärhus=2.0/2.0/2.0, Berlin=3.0/3.0/3.0, Zurich=1.0/1.0/1.0}\n\n"},
+		{[]string{"-collate", "sv"}, "{Aalborg=4.0/4.0/4.0, Berlin=3.0/3.0/3.0, Zurich=1.0/1.0/1.0, Ärhus=2.0/2.0/2.0}\n\n"},
+		{[]string{"-collate", "de", "-sort-desc"}, "{Zurich=1.0/1.0/1.0, Berlin=3.0/3.0/3.0, Ärhus=2.0/2.0/2.0, Aalborg=4.0/4.0/4.0}\n\n"},
+	}
+	for _, tc := range tests {
+		var stdout bytes.Buffer
+		require.NoError(t, run(append(tc.args, file.Name()), nil, &stdout, &bytes.Buffer{}), "%v", tc.args)
+		require.Equal(t, tc.want, stdout.String(), "%v", tc.args)
+	}
+
+	err := run([]string{"-collate", "not a locale!", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "invalid -collate locale")
+}
+
+// TestOutputOptions_DisplayName tests rune-aware truncation of multibyte names.
+func TestOutputOptions_DisplayName(t *testing.T) {
+	out := outputOptions{truncate: 5}
+	require.Equal(t, "Züri…", out.displayName("Zürich-Flughafen"))
+	require.Equal(t, "東京都新…", out.displayName("東京都新宿区"))
+	require.Equal(t, "Zürich", outputOptions{truncate: 6}.displayName("Zürich"), "names that fit are kept")
+	require.Equal(t, "…", outputOptions{truncate: 1}.displayName("Zürich"))
+	require.Equal(t, "Zürich", outputOptions{}.displayName("Zürich"))
+}
+
+// TestRun_TruncateNames tests that -truncate-names only shortens the printed names: names
+// sharing a prefix are still aggregated separately.
+func TestRun_TruncateNames(t *testing.T) {
+	file := createTestFile(t, "Zürich-Flughafen;10.0\nZürich-Fluntern;20.0\nZürich-Flughafen;12.0\nOslo;1.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-truncate-names", "8", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Oslo=1.0/1.0/1.0, Zürich-…=10.0/11.0/12.0, Zürich-…=20.0/20.0/20.0}\n\n", stdout.String())
+	require.True(t, utf8.Valid(stdout.Bytes()))
+
+	stdout.Reset()
+	require.NoError(t, run([]string{"-truncate-names", "8", "-format", "table", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Contains(t, stdout.String(), "Zürich-…    20.0")
+
+	err := run([]string{"-truncate-names", "8", "-validate-sorted", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-truncate-names can't be combined with")
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"syscall"
+	"time"
+)
+
+// chunksPerWorker is how many chunks -workers splits a file into per worker, so a worker
+// that finishes early picks up more work and the snapshots advance in smaller steps.
+const chunksPerWorker = 4
+
+// processFileChunks aggregates the file at path split into newline-aligned chunks (see
+// computeChunkOffsets), each scanned by its own processor on one of workers goroutines. The
+// file is mapped once and every chunk is scanned with scanData, like processFile scans the
+// whole file, so the chunks see the same line handling.
+//
+// The calling goroutine coordinates: it merges every partial result into p as soon as its
+// chunk completes, rather than once all are done, and calls snapshot with the number of
+// chunks merged so far whenever at least interval has passed since the previous call. The
+// snapshot runs on the coordinator, so it may read p freely. snapshot may be nil.
+func (p *processor) processFileChunks(path string, workers, chunks int, interval time.Duration, snapshot func(done, total int)) (err error) {
+	if p.groups != nil {
+		return errors.New("-sorted-input can't be used with -workers")
+	}
+	if p.tee != nil {
+		return errors.New("-tee can't be used with -workers")
+	}
+
+	offsets, err := computeChunkOffsets(path, chunks)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	data, mapped, err := p.loadFile(file)
+	if err != nil {
+		return err
+	}
+	if mapped {
+		defer func() {
+			if unmapErr := syscall.Munmap(data); unmapErr != nil && err == nil {
+				err = fmt.Errorf("could not unmap memory: %w", unmapErr)
+			}
+		}()
+	}
+	total := len(offsets) - 1
+	if int64(len(data)) != offsets[total] {
+		return fmt.Errorf("file size changed while processing: split %d bytes, mapped %d", offsets[total], len(data))
+	}
+
+	jobs := make(chan int, max(total, 0))
+	for i := range total {
+		jobs <- i
+	}
+	close(jobs)
+
+	type partialResult struct {
+		partial  *processor
+		lf, crlf int
+		err      error
+	}
+	results := make(chan partialResult)
+	for range min(workers, total) {
+		go func() {
+			for i := range jobs {
+				opts := p.opts
+				opts.seed = deriveSeed(opts.seed, fmt.Sprintf("chunk-%d", i))
+				partial := newProcessor(opts)
+				partial.logger = p.logger
+				lf, crlf, err := partial.scanChunk(data[offsets[i]:offsets[i+1]], mapped)
+				if err != nil {
+					err = fmt.Errorf("chunk at byte %d: %w", offsets[i], err)
+				}
+				results <- partialResult{partial, lf, crlf, err}
+			}
+		}()
+	}
+
+	var firstErr error
+	lf, crlf := 0, 0
+	last := time.Now()
+	for done := 1; done <= total; done++ {
+		result := <-results
+		if result.err != nil {
+			if firstErr == nil {
+				firstErr = result.err
+			}
+			continue
+		}
+		p.merge(result.partial)
+		lf, crlf = lf+result.lf, crlf+result.crlf
+		p.logger.Debug("merged chunk", "done", done, "chunks", total, "lines", p.lines)
+
+		if snapshot != nil && firstErr == nil && time.Since(last) >= interval {
+			snapshot(done, total)
+			last = time.Now()
+		}
+	}
+
+	if firstErr != nil {
+		return firstErr
+	}
+	if mapped {
+		if err = checkFileSize(file, int64(len(data))); err != nil {
+			return err
+		}
+	}
+	if lf > 0 && crlf > 0 {
+		p.logger.Warn("mixed line endings", "path", path, "lf", lf, "crlf", crlf)
+	}
+	return nil
+}
+
+// scanChunk runs scanData over one chunk of processFileChunks. The fault of reading a page the
+// file no longer backs is recovered on the worker's goroutine, as debug.SetPanicOnFault only
+// applies to the goroutine setting it.
+func (p *processor) scanChunk(chunk []byte, mapped bool) (lf, crlf int, err error) {
+	if mapped {
+		defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+		defer recoverFault(&err)
+	}
+	return p.scanData(chunk, 0, false)
+}
+
+// formatSnapshot formats a -snapshot-interval line: the chunks merged so far, the lines
+// they held and the running stats in the text format.
+func (p *processor) formatSnapshot(done, total int) string {
+	stats := p.stats
+	if p.top != nil {
+		stats = p.top.stats()
+	}
+	return fmt.Sprintf("snapshot: chunks=%d/%d lines=%d %s", done, total, p.lines, formatOutput(stats))
+}
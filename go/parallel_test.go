@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestProcessFileChunks_Snapshots tests that with a single worker the chunks merge one by
+// one, each snapshot covering more lines than the previous, and the final result equals a
+// sequential scan.
+func TestProcessFileChunks_Snapshots(t *testing.T) {
+	path := writeASCIIFixture(t, t.TempDir(), 5_000)
+
+	batch := newProcessor(options{})
+	require.NoError(t, batch.processFile(path))
+
+	p := newProcessor(options{})
+	var dones []int
+	var lines []int64
+	require.NoError(t, p.processFileChunks(path, 1, 8, 0, func(done, total int) {
+		require.Equal(t, 8, total)
+		var count float64
+		for _, tup := range p.stats {
+			count += tup[2]
+		}
+		require.Equal(t, float64(p.lines), count)
+		dones = append(dones, done)
+		lines = append(lines, p.lines)
+	}))
+
+	require.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8}, dones)
+	for i := 1; i < len(lines); i++ {
+		require.Greater(t, lines[i], lines[i-1], "snapshots are monotonic in count")
+	}
+	require.Equal(t, batch.lines, p.lines)
+	require.True(t, FromMap(batch.stats).Equal(FromMap(p.stats), 1e-9), FromMap(batch.stats).Diff(FromMap(p.stats), 1e-9))
+}
+
+// TestProcessFileChunks_Error tests that a malformed line fails the run with its chunk.
+func TestProcessFileChunks_Error(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "measurements.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("Hamburg;12.0\n", 100)+"garbage\n"), 0o600))
+
+	err := newProcessor(options{}).processFileChunks(path, 4, 16, 0, nil)
+	require.ErrorContains(t, err, "could not parse line: garbage")
+}
+
+// TestProcessFileChunks_SameLines tests that chunks handle lines like a sequential scan does:
+// CRLF line endings are accepted and the stdin-only -max-bytes and -max-line-bytes limits
+// don't cut the chunks short.
+func TestProcessFileChunks_SameLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "measurements.txt")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Repeat("Hamburg;12.0\r\nBerlin;-3.5\r\n", 1_000)), 0o600))
+	opts := options{maxBytes: 100, maxLineBytes: 4}
+
+	batch := newProcessor(opts)
+	require.NoError(t, batch.processFile(path))
+
+	p := newProcessor(opts)
+	require.NoError(t, p.processFileChunks(path, 2, 8, 0, nil))
+	require.Equal(t, int64(2_000), p.lines)
+	require.Equal(t, batch.stats, p.stats)
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_Workers tests that -workers produces the sequential output and prints snapshots.
+func TestRun_Workers(t *testing.T) {
+	// Halves sum exactly in any order, so the chunked output can be compared as text.
+	var data strings.Builder
+	for i := range 2_000 {
+		fmt.Fprintf(&data, "Station %d;%.1f\n", i%7, float64(i%41-20)/2)
+	}
+	path := filepath.Join(t.TempDir(), "measurements.txt")
+	require.NoError(t, os.WriteFile(path, []byte(data.String()), 0o600))
+
+	var want bytes.Buffer
+	require.NoError(t, run([]string{path}, nil, &want, &bytes.Buffer{}))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"-workers", "3", "-snapshot-interval", "1ns", path}, nil, &stdout, &stderr))
+	require.Equal(t, want.String(), stdout.String())
+	require.Contains(t, stderr.String(), "snapshot: chunks=12/12 lines=2000 {")
+
+	err := run([]string{"-snapshot-interval", "1s", path}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.EqualError(t, err, "-snapshot-interval requires -workers")
+}
+
+// TestRun_WorkersCount tests that the output doesn't depend on the number of workers, and that
+// -limit-stations, which would be applied per chunk, is rejected.
+func TestRun_WorkersCount(t *testing.T) {
+	var data strings.Builder
+	for i := range 2_000 {
+		fmt.Fprintf(&data, "S%d;%.1f\n", i%11, float64(i%41-20)/2)
+	}
+	path := filepath.Join(t.TempDir(), "measurements.txt")
+	require.NoError(t, os.WriteFile(path, []byte(data.String()), 0o600))
+
+	var one, four bytes.Buffer
+	require.NoError(t, run([]string{"-workers", "1", path}, nil, &one, &bytes.Buffer{}))
+	require.NoError(t, run([]string{"-workers", "4", path}, nil, &four, &bytes.Buffer{}))
+	require.Equal(t, one.String(), four.String())
+
+	err := run([]string{"-limit-stations", "3", "-workers", "4", path}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.EqualError(t, err, "-workers can't be combined with -limit-stations")
+}
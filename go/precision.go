@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// precisionSampleLines is how many lines -decimal-places-detect inspects.
+const precisionSampleLines = 1000
+
+// detectPrecision returns the largest number of decimal places among the temperatures of
+// the first k lines of r, with sep as the field separator. A unit suffix after the digits
+// is ignored, and lines without a separator or too long to sample (see sampleLines) are
+// skipped.
+func detectPrecision(r io.Reader, k int, sep byte) (int, error) {
+	precision, lines := 0, 0
+	if k < 1 {
+		return precision, nil
+	}
+	err := sampleLines(r, func(line []byte) bool {
+		if lastSep := bytes.LastIndexByte(line, sep); lastSep != -1 {
+			precision = max(precision, decimalPlaces(line[lastSep+1:]))
+		}
+		lines++
+		return lines < k
+	})
+	if err != nil {
+		return 0, err
+	}
+	return precision, nil
+}
+
+// decimalPlaces returns the number of digits after the decimal point of temperature, 0
+// without one.
+func decimalPlaces(temperature []byte) int {
+	dot := bytes.IndexByte(temperature, '.')
+	if dot == -1 {
+		return 0
+	}
+	decimals := 0
+	for _, b := range temperature[dot+1:] {
+		if !isDigit(b) {
+			break
+		}
+		decimals++
+	}
+	return decimals
+}
+
+// detectFilePrecision runs detectPrecision on the first precisionSampleLines lines of the
+// file at path.
+func detectFilePrecision(path string, sep byte) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("could not open file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	return detectPrecision(file, precisionSampleLines, sep)
+}
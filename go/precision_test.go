@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestDetectPrecision tests the largest decimal count is found within the sampled lines only.
+func TestDetectPrecision(t *testing.T) {
+	input := "Berlin;12.5\nHamburg;8\nOslo;-3.25C\nbroken\nRome;1.125\n"
+
+	precision, err := detectPrecision(strings.NewReader(input), 4, ';')
+	require.NoError(t, err)
+	require.Equal(t, 2, precision, "Rome is past the sample")
+
+	precision, err = detectPrecision(strings.NewReader(input), 10, ';')
+	require.NoError(t, err)
+	require.Equal(t, 3, precision)
+
+	precision, err = detectPrecision(strings.NewReader("Berlin 12\n"), 10, ' ')
+	require.NoError(t, err)
+	require.Equal(t, 0, precision)
+
+	long := strings.Repeat("x", 2*sampleLineBytes) + ";1.1234\nBerlin;12.25\n"
+	precision, err = detectPrecision(strings.NewReader(long), 10, ';')
+	require.NoError(t, err)
+	require.Equal(t, 2, precision, "an overlong line is skipped, not fatal")
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_DecimalPlacesDetect tests that two-decimal data is printed with two decimals.
+func TestRun_DecimalPlacesDetect(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.25\nBerlin;20.5\nHamburg;8.10\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-decimal-places-detect", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.50/20.50/20.50, Hamburg=8.10/10.18/12.25}\n\n", stdout.String())
+
+	stdout.Reset()
+	require.NoError(t, run([]string{"-decimal-places-detect", "-format", "table", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Contains(t, stdout.String(), "Hamburg    8.10   10.18   12.25")
+}
+
+// TestRun_DecimalPlacesDetectEverywhere tests that a detected precision, including zero for
+// integer data, applies to the annotations and the -summary footer too.
+func TestRun_DecimalPlacesDetectEverywhere(t *testing.T) {
+	integers := createTestFile(t, "Oslo;1\nOslo;3\nOslo;3\n")
+	defer cleanupTestFile(t, integers)
+
+	var stdout bytes.Buffer
+	args := []string{"-decimal-places-detect", "-mode", "-first-last", "-summary", "-summary-to", summaryToStderr}
+	var stderr bytes.Buffer
+	require.NoError(t, run(append(args, integers.Name()), nil, &stdout, &stderr))
+	require.Equal(t, "{Oslo=1/2/3 mode=3 first=1 last=3}\n\n", stdout.String())
+	require.Contains(t, stderr.String(), "summary: records=3 stations=1 min=1 max=3 ")
+
+	hundredths := createTestFile(t, "A;1.25\nA;2.50\n")
+	defer cleanupTestFile(t, hundredths)
+
+	stdout.Reset()
+	stderr.Reset()
+	require.NoError(t, run(append(args, hundredths.Name()), nil, &stdout, &stderr))
+	require.Equal(t, "{A=1.25/1.88/2.50 mode=1.30 first=1.25 last=2.50}\n\n", stdout.String())
+	require.Contains(t, stderr.String(), "summary: records=2 stations=1 min=1.25 max=2.50 ")
+}
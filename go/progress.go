@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// progressStep is the number of bytes scanned between two progress callbacks.
+	progressStep = 1 << 20
+
+	progressBarWidth    = 40
+	progressBarInterval = 100 * time.Millisecond
+)
+
+// progressBar draws a textual progress bar, redrawn in place with carriage returns.
+// Redraws are throttled to one per interval, except for the final one.
+type progressBar struct {
+	w        io.Writer
+	width    int
+	interval time.Duration
+	now      func() time.Time
+	last     time.Time
+	drawn    bool
+}
+
+// newProgressBar creates a progress bar writing to w, typically stderr.
+func newProgressBar(w io.Writer) *progressBar {
+	return &progressBar{w: w, width: progressBarWidth, interval: progressBarInterval, now: time.Now}
+}
+
+// update redraws the bar for done out of total bytes, unless the last redraw was too recent.
+func (b *progressBar) update(done, total int64) {
+	now := b.now()
+	if b.drawn && done < total && now.Sub(b.last) < b.interval {
+		return
+	}
+	b.last, b.drawn = now, true
+	_, _ = fmt.Fprint(b.w, "\r"+renderProgress(done, total, b.width))
+}
+
+// finish ends the bar's line so later stderr output starts on a fresh one.
+func (b *progressBar) finish() {
+	if b.drawn {
+		_, _ = fmt.Fprintln(b.w)
+	}
+}
+
+// progressEvent is one line written by -progress-json.
+type progressEvent struct {
+	Bytes    int64 `json:"bytes"`
+	Total    int64 `json:"total"`
+	Stations int   `json:"stations"`
+}
+
+// progressJSON writes progress as JSON lines, for programs wrapping the command. Like the
+// bar, events are throttled to one per interval, except for the final one.
+type progressJSON struct {
+	w        io.Writer
+	stations func() int // distinct stations aggregated so far
+	interval time.Duration
+	now      func() time.Time
+	last     time.Time
+	emitted  bool
+}
+
+// newProgressJSON creates a JSON progress writer to w, typically stderr.
+func newProgressJSON(w io.Writer, stations func() int, interval time.Duration) *progressJSON {
+	return &progressJSON{w: w, stations: stations, interval: interval, now: time.Now}
+}
+
+// update emits an event for done out of total bytes, unless the last one was too recent.
+func (j *progressJSON) update(done, total int64) {
+	now := j.now()
+	if j.emitted && done < total && now.Sub(j.last) < j.interval {
+		return
+	}
+	j.last, j.emitted = now, true
+	line, _ := json.Marshal(progressEvent{Bytes: done, Total: total, Stations: j.stations()}) // can't fail
+	_, _ = fmt.Fprintf(j.w, "%s\n", line)
+}
+
+// renderProgress formats a bar of the given width, e.g. `[#####-----]  50.0%`.
+func renderProgress(done, total int64, width int) string {
+	fraction := 1.0
+	if total > 0 {
+		fraction = min(max(float64(done)/float64(total), 0), 1)
+	}
+	filled := int(fraction * float64(width))
+	return fmt.Sprintf("[%s%s] %5.1f%%", strings.Repeat("#", filled), strings.Repeat("-", width-filled), fraction*100)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestRenderProgress tests the bar formatting at various percentages.
+func TestRenderProgress(t *testing.T) {
+	tests := []struct {
+		done, total int64
+		want        string
+	}{
+		{0, 100, "[----------]   0.0%"},
+		{25, 100, "[##--------]  25.0%"},
+		{50, 100, "[#####-----]  50.0%"},
+		{999, 1000, "[#########-]  99.9%"},
+		{100, 100, "[##########] 100.0%"},
+		{150, 100, "[##########] 100.0%"},
+		{0, 0, "[##########] 100.0%"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, renderProgress(tt.done, tt.total, 10), "%d/%d", tt.done, tt.total)
+	}
+}
+
+// TestProgressBar_Throttle tests that redraws within the interval are dropped, except the final one.
+func TestProgressBar_Throttle(t *testing.T) {
+	var buf bytes.Buffer
+	clock := time.Unix(0, 0)
+	bar := newProgressBar(&buf)
+	bar.width = 4
+	bar.now = func() time.Time { return clock }
+
+	bar.update(1, 4)
+	bar.update(2, 4) // throttled
+	clock = clock.Add(progressBarInterval)
+	bar.update(3, 4)
+	bar.update(4, 4) // final, never throttled
+	bar.finish()
+
+	require.Equal(t, "\r[#---]  25.0%\r[###-]  75.0%\r[####] 100.0%\n", buf.String())
+}
+
+// TestProgressJSON_Throttle tests that events within the interval are dropped, except the final one.
+func TestProgressJSON_Throttle(t *testing.T) {
+	var buf bytes.Buffer
+	clock := time.Unix(0, 0)
+	progress := newProgressJSON(&buf, func() int { return 3 }, time.Second)
+	progress.now = func() time.Time { return clock }
+
+	progress.update(10, 40)
+	progress.update(20, 40) // throttled
+	clock = clock.Add(time.Second)
+	progress.update(30, 40)
+	progress.update(40, 40) // final, never throttled
+
+	require.Equal(t, `{"bytes":10,"total":40,"stations":3}
+{"bytes":30,"total":40,"stations":3}
+{"bytes":40,"total":40,"stations":3}
+`, buf.String())
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestProcessor_Progress tests that processFile reports its progress up to the file size.
+func TestProcessor_Progress(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\n")
+	defer cleanupTestFile(t, file)
+
+	var done, total int64
+	p := newProcessor(options{})
+	p.progress = func(d, t int64) { done, total = d, t }
+	require.NoError(t, p.processFile(file.Name()))
+	require.Equal(t, int64(25), total)
+	require.Equal(t, total, done)
+}
+
+// TestRun_ProgressJSON tests that -progress-json writes JSON events to stderr, the last of
+// them covering the whole file and every station.
+func TestRun_ProgressJSON(t *testing.T) {
+	line := "Hamburg;12.0\nBerlin;20.0\n"
+	file := createTestFile(t, strings.Repeat(line, 3*progressStep/len(line)))
+	defer cleanupTestFile(t, file)
+
+	var stderr bytes.Buffer
+	require.NoError(t, run([]string{"-progress-json", "-progress-interval", "0", file.Name()}, nil, &bytes.Buffer{}, &stderr))
+
+	lines := strings.Split(strings.TrimSuffix(stderr.String(), "\n"), "\n")
+	require.Greater(t, len(lines), 1, "events are emitted while scanning")
+	var events []progressEvent
+	for _, line := range lines {
+		var event progressEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &event), line)
+		events = append(events, event)
+	}
+	final := events[len(events)-1]
+	require.Equal(t, final.Total, final.Bytes)
+	require.Equal(t, 2, final.Stations)
+
+	err := run([]string{"-progress-json", "-progress-bar", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.EqualError(t, err, "-progress-json can't be combined with -progress-bar")
+}
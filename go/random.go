@@ -0,0 +1,26 @@
+package main
+
+import (
+	"hash/fnv"
+	"math/rand/v2"
+	"time"
+)
+
+// newRand creates the random source for a run. Every randomized code path draws from a
+// *rand.Rand created here, so a run with a fixed -seed is fully reproducible.
+func newRand(seed uint64) *rand.Rand {
+	return rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+}
+
+// timeSeed returns a seed derived from the current time, used when -seed isn't given.
+func timeSeed() uint64 {
+	return uint64(time.Now().UnixNano())
+}
+
+// deriveSeed mixes name into seed, giving independent but reproducible random streams
+// to work units that run concurrently, such as the files of a directory.
+func deriveSeed(seed uint64, name string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(name))
+	return seed ^ h.Sum64()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestNewRand_Reproducible tests that equal seeds give equal streams and different seeds don't.
+func TestNewRand_Reproducible(t *testing.T) {
+	a, b, c := newRand(42), newRand(42), newRand(43)
+	for i := 0; i < 10; i++ {
+		require.Equal(t, a.Uint64(), b.Uint64())
+	}
+	require.NotEqual(t, newRand(42).Uint64(), c.Uint64())
+
+	require.NotEqual(t, deriveSeed(42, "a.txt"), deriveSeed(42, "b.txt"))
+	require.Equal(t, deriveSeed(42, "a.txt"), deriveSeed(42, "a.txt"))
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_SeedReproducibleSample tests that two sampled runs with the same seed are identical.
+func TestRun_SeedReproducibleSample(t *testing.T) {
+	var data strings.Builder
+	for i := 0; i < 1_000; i++ {
+		fmt.Fprintf(&data, "Station%d;%d.%d\n", i%7, i%50, i%10)
+	}
+	file := createTestFile(t, data.String())
+	defer cleanupTestFile(t, file)
+
+	sample := func(seed string) string {
+		var stdout bytes.Buffer
+		require.NoError(t, run([]string{"-sample", "0.3", "-seed", seed, file.Name()}, nil, &stdout, &bytes.Buffer{}))
+		return stdout.String()
+	}
+
+	first, second := sample("7"), sample("7")
+	require.Equal(t, first, second)
+	require.NotEqual(t, first, sample("8"))
+
+	var full bytes.Buffer
+	require.NoError(t, run([]string{file.Name()}, nil, &full, &bytes.Buffer{}))
+	require.NotEqual(t, full.String(), first, "a 30% sample shouldn't match the full aggregation")
+}
+
+// TestRun_SeedLoggedWhenVerbose tests that the chosen seed is printed in verbose mode.
+func TestRun_SeedLoggedWhenVerbose(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stderr bytes.Buffer
+	require.NoError(t, run([]string{"-v", "-seed", "1234", file.Name()}, nil, &bytes.Buffer{}, &stderr))
+	require.Contains(t, stderr.String(), "seed=1234")
+
+	stderr.Reset()
+	require.NoError(t, run([]string{"-v", file.Name()}, nil, &bytes.Buffer{}, &stderr))
+	require.Contains(t, stderr.String(), "msg=\"random seed\" seed=")
+
+	err := run([]string{"-sample", "1.5", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.EqualError(t, err, "sample rate must be between 0 and 1, got 1.5")
+}
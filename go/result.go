@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"slices"
+)
+
+// Stats is the lossless aggregate of a single station's readings.
+//
+// Unlike StationStat it keeps the sum rather than the mean, so two Stats can be
+// merged exactly.
+type Stats struct {
+	Min   float64
+	Sum   float64
+	Count int64
+	Max   float64
+}
+
+// Mean returns the average of the station's readings.
+func (s Stats) Mean() float64 {
+	return s.Sum / float64(s.Count)
+}
+
+// merge combines two aggregates exactly. Merging with the zero Stats returns the other side.
+func (s Stats) merge(other Stats) Stats {
+	if s.Count == 0 {
+		return other
+	}
+	if other.Count == 0 {
+		return s
+	}
+	return Stats{
+		Min:   min(s.Min, other.Min),
+		Sum:   s.Sum + other.Sum,
+		Count: s.Count + other.Count,
+		Max:   max(s.Max, other.Max),
+	}
+}
+
+// Result maps each station name to its aggregated statistics.
+type Result map[string]Stats
+
+// FromMap converts [min, sum, count, max] tuples, the processor's representation, into a
+// Result. It is the inverse of ToMap.
+func FromMap(stats map[string][4]float64) Result {
+	result := make(Result, len(stats))
+	for station, tup := range stats {
+		result[station] = Stats{Min: tup[0], Sum: tup[1], Count: int64(tup[2]), Max: tup[3]}
+	}
+	return result
+}
+
+// ToMap converts r into [min, sum, count, max] tuples, for code still working with the
+// processor's representation. It is the inverse of FromMap.
+func (r Result) ToMap() map[string][4]float64 {
+	stats := make(map[string][4]float64, len(r))
+	for station, s := range r {
+		stats[station] = [4]float64{s.Min, s.Sum, float64(s.Count), s.Max}
+	}
+	return stats
+}
+
+// Merge folds other into r, combining stations present in both exactly: min and max are
+// kept and sums and counts added, so the merged means are those of all readings. Merging
+// results is lossless, unlike combining their formatted output.
+func (r Result) Merge(other Result) {
+	for station, s := range other {
+		r[station] = r[station].merge(s)
+	}
+}
+
+// Filter returns a new Result with only the stations for which keep returns true.
+func (r Result) Filter(keep func(name string, s Stats) bool) Result {
+	filtered := make(Result)
+	for station, s := range r {
+		if keep(station, s) {
+			filtered[station] = s
+		}
+	}
+	return filtered
+}
+
+// MapKeys returns a new Result with every station renamed by rename. Stations renamed to
+// the same name are merged exactly.
+func (r Result) MapKeys(rename func(name string) string) Result {
+	mapped := make(Result, len(r))
+	for station, s := range r {
+		name := rename(station)
+		mapped[name] = mapped[name].merge(s)
+	}
+	return mapped
+}
+
+// Summary is the overall aggregate of a Result, the programmatic counterpart of the
+// -summary footer.
+type Summary struct {
+	TotalStations int
+	TotalReadings int64
+	GlobalMin     float64
+	GlobalMax     float64
+	GlobalMean    float64 // mean of all readings, so stations weigh by their count
+}
+
+// Summary folds every station into the overall aggregate. Stations without readings count
+// towards TotalStations only; if there are no readings at all, the global min, max and
+// mean are NaN.
+func (r Result) Summary() Summary {
+	var total Stats
+	for _, s := range r {
+		total = total.merge(s)
+	}
+	summary := Summary{TotalStations: len(r), TotalReadings: total.Count}
+	if total.Count == 0 {
+		summary.GlobalMin, summary.GlobalMax, summary.GlobalMean = math.NaN(), math.NaN(), math.NaN()
+		return summary
+	}
+	summary.GlobalMin, summary.GlobalMax, summary.GlobalMean = total.Min, total.Max, total.Mean()
+	return summary
+}
+
+// Equal reports whether r and other have the same stations with the same count and a
+// min, mean and max within tolerance of each other. Diff describes the first mismatch.
+func (r Result) Equal(other Result, tolerance float64) bool {
+	return r.Diff(other, tolerance) == ""
+}
+
+// Diff describes the first difference between r and other, checking stations in name
+// order, or returns "" when they are Equal within tolerance.
+func (r Result) Diff(other Result, tolerance float64) string {
+	for _, station := range slices.Sorted(maps.Keys(r)) {
+		if _, exists := other[station]; !exists {
+			return fmt.Sprintf("station %q is missing from the other result", station)
+		}
+	}
+	for _, station := range slices.Sorted(maps.Keys(other)) {
+		s, exists := r[station]
+		if !exists {
+			return fmt.Sprintf("station %q is only in the other result", station)
+		}
+
+		o := other[station]
+		if s.Count != o.Count {
+			return fmt.Sprintf("station %q: count %d != %d", station, s.Count, o.Count)
+		}
+		for _, metric := range []struct {
+			name string
+			a, b float64
+		}{
+			{"min", s.Min, o.Min},
+			{"mean", s.Mean(), o.Mean()},
+			{"max", s.Max, o.Max},
+		} {
+			if math.Abs(metric.a-metric.b) > tolerance {
+				return fmt.Sprintf("station %q: %s %g != %g", station, metric.name, metric.a, metric.b)
+			}
+		}
+	}
+	return ""
+}
+
+// EncodeGob writes the result to w using encoding/gob, so it can be reloaded with
+// DecodeGob without reprocessing the measurements.
+func EncodeGob(w io.Writer, result Result) error {
+	if err := gob.NewEncoder(w).Encode(result); err != nil {
+		return fmt.Errorf("could not encode result: %w", err)
+	}
+	return nil
+}
+
+// DecodeGob reads a result previously written by EncodeGob.
+func DecodeGob(r io.Reader) (Result, error) {
+	var result Result
+	if err := gob.NewDecoder(r).Decode(&result); err != nil {
+		return nil, fmt.Errorf("could not decode result: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestFromMap tests the conversion from [min, sum, count, max] tuples.
+func TestFromMap(t *testing.T) {
+	result := FromMap(map[string][4]float64{
+		"Hamburg": {9.0, 36.0, 3.0, 15.0},
+	})
+
+	require.Equal(t, Result{"Hamburg": {Min: 9.0, Sum: 36.0, Count: 3, Max: 15.0}}, result)
+	require.InDelta(t, 12.0, result["Hamburg"].Mean(), 1e-9)
+}
+
+// TestResult_ToMap tests that ToMap -> FromMap -> ToMap round-trips the tuples unchanged.
+func TestResult_ToMap(t *testing.T) {
+	stats := map[string][4]float64{
+		"Berlin":  {-3.2, 45.1, 3, 25.0},
+		"Hamburg": {8.0, 20.0, 2, 12.0},
+		"Tokyo":   {}, // an -emit-empty placeholder
+	}
+
+	result := FromMap(stats)
+	require.Equal(t, stats, result.ToMap())
+	require.Equal(t, result, FromMap(result.ToMap()))
+	require.Equal(t, stats, FromMap(FromMap(stats).ToMap()).ToMap())
+	require.Empty(t, Result{}.ToMap())
+}
+
+// TestResult_Equal tests equal, differing and within-tolerance results, and the Diff of each.
+func TestResult_Equal(t *testing.T) {
+	base := Result{
+		"Berlin":  {Min: -3.2, Sum: 45.0, Count: 3, Max: 25.0},
+		"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+	}
+
+	tests := []struct {
+		name  string
+		other Result
+		diff  string
+	}{
+		{"equal", Result{
+			"Berlin":  {Min: -3.2, Sum: 45.0, Count: 3, Max: 25.0},
+			"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+		}, ""},
+		{"within tolerance", Result{
+			"Berlin":  {Min: -3.2000001, Sum: 45.0000003, Count: 3, Max: 25.0},
+			"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 11.9999999},
+		}, ""},
+		{"differing count", Result{
+			"Berlin":  {Min: -3.2, Sum: 45.0, Count: 4, Max: 25.0},
+			"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+		}, `station "Berlin": count 3 != 4`},
+		{"beyond tolerance", Result{
+			"Berlin":  {Min: -3.2, Sum: 45.0, Count: 3, Max: 25.0},
+			"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.1},
+		}, `station "Hamburg": max 12 != 12.1`},
+		{"missing station", Result{
+			"Berlin": {Min: -3.2, Sum: 45.0, Count: 3, Max: 25.0},
+		}, `station "Hamburg" is missing from the other result`},
+		{"extra station", Result{
+			"Berlin":  {Min: -3.2, Sum: 45.0, Count: 3, Max: 25.0},
+			"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+			"Oslo":    {Min: 1.0, Sum: 1.0, Count: 1, Max: 1.0},
+		}, `station "Oslo" is only in the other result`},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.diff, base.Diff(tc.other, 1e-6))
+			require.Equal(t, tc.diff == "", base.Equal(tc.other, 1e-6))
+		})
+	}
+}
+
+// TestResult_Filter tests keeping only the stations with enough readings.
+func TestResult_Filter(t *testing.T) {
+	result := Result{
+		"Berlin":  {Min: -3.2, Sum: 45.0, Count: 3, Max: 25.0},
+		"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+		"Oslo":    {Min: 1.0, Sum: 1.0, Count: 1, Max: 1.0},
+	}
+
+	filtered := result.Filter(func(_ string, s Stats) bool { return s.Count >= 2 })
+
+	require.Equal(t, Result{
+		"Berlin":  {Min: -3.2, Sum: 45.0, Count: 3, Max: 25.0},
+		"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+	}, filtered)
+	require.Len(t, result, 3, "the receiver is left untouched")
+}
+
+// TestResult_MapKeys tests that stations renamed to the same name are merged.
+func TestResult_MapKeys(t *testing.T) {
+	result := Result{
+		"Berlin-Mitte":  {Min: -3.2, Sum: 45.0, Count: 3, Max: 25.0},
+		"Berlin-Pankow": {Min: -5.0, Sum: 10.0, Count: 2, Max: 15.0},
+		"Hamburg":       {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+	}
+
+	mapped := result.MapKeys(func(name string) string {
+		city, _, _ := strings.Cut(name, "-")
+		return city
+	})
+
+	require.Equal(t, Result{
+		"Berlin":  {Min: -5.0, Sum: 55.0, Count: 5, Max: 25.0},
+		"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+	}, mapped)
+}
+
+// TestResult_Summary tests the overall aggregate, whose mean weighs stations by their
+// count rather than averaging their means.
+func TestResult_Summary(t *testing.T) {
+	result := Result{
+		"Berlin":  {Min: -3.0, Sum: 45.0, Count: 3, Max: 25.0},
+		"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+		"Oslo":    {Min: 40.0, Sum: 40.0, Count: 1, Max: 40.0},
+		"Tokyo":   {}, // an -emit-empty placeholder
+	}
+
+	summary := result.Summary()
+	require.Equal(t, 4, summary.TotalStations)
+	require.Equal(t, int64(6), summary.TotalReadings)
+	require.Equal(t, -3.0, summary.GlobalMin)
+	require.Equal(t, 40.0, summary.GlobalMax)
+	require.InDelta(t, 17.5, summary.GlobalMean, 1e-9, "105 / 6, not the mean of the means (21.67)")
+
+	empty := Result{"Tokyo": {}}.Summary()
+	require.Equal(t, 1, empty.TotalStations)
+	require.Zero(t, empty.TotalReadings)
+	require.True(t, math.IsNaN(empty.GlobalMin) && math.IsNaN(empty.GlobalMax) && math.IsNaN(empty.GlobalMean))
+}
+
+// TestResult_Merge tests merging results with overlapping and disjoint stations.
+func TestResult_Merge(t *testing.T) {
+	result := Result{
+		"Berlin":  {Min: -3.2, Sum: 45.0, Count: 3, Max: 25.0},
+		"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+	}
+	other := Result{
+		"Hamburg": {Min: 5.0, Sum: 15.0, Count: 2, Max: 10.0},
+		"Oslo":    {Min: -5.0, Sum: -5.0, Count: 1, Max: -5.0},
+	}
+
+	result.Merge(other)
+
+	require.Equal(t, Result{
+		"Berlin":  {Min: -3.2, Sum: 45.0, Count: 3, Max: 25.0},
+		"Hamburg": {Min: 5.0, Sum: 35.0, Count: 4, Max: 12.0},
+		"Oslo":    {Min: -5.0, Sum: -5.0, Count: 1, Max: -5.0},
+	}, result)
+	require.InDelta(t, 8.75, result["Hamburg"].Mean(), 1e-9)
+	require.Len(t, other, 2, "the merged result is left untouched")
+}
+
+// TestGob_RoundTrip tests that a result survives EncodeGob followed by DecodeGob.
+func TestGob_RoundTrip(t *testing.T) {
+	original := Result{
+		"Berlin":  {Min: -3.2, Sum: 45.15, Count: 3, Max: 25.0},
+		"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+		"東京":      {Min: 25.0, Sum: 25.0, Count: 1, Max: 25.0},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, EncodeGob(&buf, original))
+
+	decoded, err := DecodeGob(&buf)
+	require.NoError(t, err)
+	require.Equal(t, original, decoded)
+}
+
+// TestDecodeGob_Invalid tests that garbage input is reported as an error.
+func TestDecodeGob_Invalid(t *testing.T) {
+	_, err := DecodeGob(bytes.NewReader([]byte("not gob")))
+	require.Error(t, err)
+}
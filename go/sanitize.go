@@ -0,0 +1,19 @@
+package main
+
+import "strings"
+
+// byteOrderMark is the UTF-8 encoded BOM some editors put at the start of a file, or of
+// each file concatenated into one.
+const byteOrderMark = "\xef\xbb\xbf"
+
+// sanitizeLine applies the -sanitize bundle to a raw line: it strips a byte order mark, a
+// trailing '\r' and surrounding whitespace, and reports false for lines to skip, which are
+// blank lines and `#` comments. Whitespace around the fields is trimmed by parseLine.
+func sanitizeLine(line string) (string, bool) {
+	line = strings.TrimPrefix(line, byteOrderMark)
+	line = strings.TrimSpace(line) // includes the '\r' of CRLF line endings
+	if line == "" || line[0] == '#' {
+		return "", false
+	}
+	return line, true
+}
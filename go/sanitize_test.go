@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestSanitizeLine tests each cleanup of the -sanitize bundle.
+func TestSanitizeLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+		keep bool
+	}{
+		{"Berlin;12.0", "Berlin;12.0", true},
+		{"\xef\xbb\xbfBerlin;12.0", "Berlin;12.0", true},
+		{"  Berlin ; 12.0 \r", "Berlin ; 12.0", true},
+		{" \t\r", "", false},
+		{"# station;temperature", "", false},
+		{"  # indented comment", "", false},
+	}
+	for _, tc := range tests {
+		line, keep := sanitizeLine(tc.line)
+		require.Equal(t, tc.want, line, "%q", tc.line)
+		require.Equal(t, tc.keep, keep, "%q", tc.line)
+	}
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_Sanitize tests a file with a BOM, CRLF line endings, padded fields, blank lines
+// and comments, both memory-mapped and streamed from stdin.
+func TestRun_Sanitize(t *testing.T) {
+	data := "\xef\xbb\xbf# exported by sensor hub\r\n" +
+		"Hamburg;12.0\r\n" +
+		"\r\n" +
+		"  Berlin ;\t20.0  \n" +
+		"   \n" +
+		"# Hamburg;99.0\n" +
+		"Hamburg ; 8.0\r\n"
+	file := createTestFile(t, data)
+	defer cleanupTestFile(t, file)
+	want := "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n\n"
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-sanitize", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, want, stdout.String())
+
+	stdout.Reset()
+	require.NoError(t, run([]string{"-sanitize", "-"}, bytes.NewBufferString(data), &stdout, &bytes.Buffer{}))
+	require.Equal(t, want, stdout.String())
+
+	require.Error(t, run([]string{file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{}), "the file is rejected without -sanitize")
+}
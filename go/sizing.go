@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// defaultSizingSample is how many bytes -adaptive-sizing samples by default.
+const defaultSizingSample = 16 << 20
+
+// estimateStations counts the distinct stations in the first limit bytes of r, with sep as
+// the field separator, to pre-size the stats map. Station sets saturate quickly in
+// practice, so the count over a sample is a good hint for the whole input; a line cut by
+// the limit adds at most one spurious station, which is harmless for a hint.
+func estimateStations(r io.Reader, limit int64, sep byte) (int, error) {
+	seen := make(map[string]struct{})
+	err := sampleLines(io.LimitReader(r, limit), func(line []byte) bool {
+		if lastSep := bytes.LastIndexByte(line, sep); lastSep != -1 {
+			seen[string(line[:lastSep])] = struct{}{}
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(seen), nil
+}
+
+// sampleLineBytes is the longest line a pre-pass over a sample of the input looks at.
+const sampleLineBytes = 64 << 10
+
+// sampleLines calls fn with each line of r, without its line ending, until fn returns false.
+// Lines longer than sampleLineBytes are skipped rather than failing: a pre-pass only needs a
+// sample, and the scan proper deals with such lines according to the options.
+func sampleLines(r io.Reader, fn func(line []byte) bool) error {
+	reader := bufio.NewReaderSize(r, sampleLineBytes)
+	for {
+		line, err := reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			for errors.Is(err, bufio.ErrBufferFull) {
+				_, err = reader.ReadSlice('\n') // the rest of the overlong line
+			}
+			line = nil
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("could not read input: %w", err)
+		}
+		if line != nil && (len(line) > 0 || err == nil) {
+			line = bytes.TrimSuffix(bytes.TrimSuffix(line, []byte("\n")), []byte("\r"))
+			if !fn(line) {
+				return nil
+			}
+		}
+		if err != nil { // io.EOF
+			return nil
+		}
+	}
+}
+
+// estimateFileStations runs estimateStations on the file at path.
+func estimateFileStations(path string, limit int64, sep byte) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("could not open file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	return estimateStations(file, limit, sep)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestEstimateStations tests the estimate over a whole input and over a prefix of it.
+func TestEstimateStations(t *testing.T) {
+	const stations = 500
+	var data strings.Builder
+	for i := range 10 * stations {
+		fmt.Fprintf(&data, "station-%03d;%d.0\n", i%stations, i%40)
+	}
+
+	estimate, err := estimateStations(strings.NewReader(data.String()), int64(data.Len()), ';')
+	require.NoError(t, err)
+	require.Equal(t, stations, estimate)
+
+	estimate, err = estimateStations(strings.NewReader(data.String()), int64(data.Len()/4), ';')
+	require.NoError(t, err)
+	require.Equal(t, stations, estimate, "every station appears within the first quarter")
+
+	estimate, err = estimateStations(strings.NewReader(data.String()), 100*16, ';')
+	require.NoError(t, err)
+	require.True(t, 90 <= estimate && estimate <= 100, "a 1600-byte sample sees one station per 16-17 byte line, got %d", estimate)
+}
+
+// TestEstimateStations_LongLine tests that a line longer than sampleLineBytes is skipped
+// instead of failing the pre-pass, and that the lines around it still count.
+func TestEstimateStations_LongLine(t *testing.T) {
+	data := "Hamburg;12.0\n" + strings.Repeat("x", 3*sampleLineBytes) + ";1.0\r\nBerlin;20.0\r\nOslo;-5.0"
+
+	estimate, err := estimateStations(strings.NewReader(data), int64(len(data)), ';')
+	require.NoError(t, err)
+	require.Equal(t, 3, estimate)
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_AdaptiveSizing tests that pre-sizing the map leaves the results unchanged, on the
+// default and the -assume-ascii paths.
+func TestRun_AdaptiveSizing(t *testing.T) {
+	path := writeASCIIFixture(t, t.TempDir(), 10_000)
+
+	for _, extra := range [][]string{nil, {"-assume-ascii"}} {
+		var want, got, stderr bytes.Buffer
+		require.NoError(t, run(append(extra, path), nil, &want, &bytes.Buffer{}))
+		require.NoError(t, run(append(extra, "-adaptive-sizing", "-sizing-sample", "4096", "-v", path), nil, &got, &stderr))
+		require.Equal(t, want.String(), got.String(), "%v", extra)
+		require.Contains(t, stderr.String(), "estimated stations")
+	}
+
+	err := run([]string{"-adaptive-sizing", "-sizing-sample", "0", path}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.EqualError(t, err, "-sizing-sample must be at least 1")
+}
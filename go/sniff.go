@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	sepAuto    = "auto"   // -sep value selecting autodetectSeparator
+	sniffBytes = 64 << 10 // bytes of input -sep auto examines
+	sniffLines = 20       // non-comment lines -sep auto examines
+	sniffSeps  = ";,\t"   // the separators -sep auto chooses from
+)
+
+// autodetectSeparator sniffs the field separator of the sample, the start of an input: the
+// candidate occurring exactly once on every one of its first sniffLines non-blank, non-comment
+// lines. A trailing line cut off by the end of the sample is ignored. It fails when no
+// candidate, or more than one, is consistent.
+func autodetectSeparator(sample []byte) (byte, error) {
+	sample = bytes.TrimPrefix(sample, []byte(byteOrderMark))
+	if cut := bytes.LastIndexByte(sample, '\n'); cut != -1 {
+		sample = sample[:cut] // the last line may be incomplete, unless it's the only one
+	}
+
+	var lines [][]byte
+	for line := range bytes.Lines(sample) {
+		line = bytes.TrimRight(line, "\r\n")
+		if len(bytes.TrimSpace(line)) == 0 || line[0] == '#' {
+			continue
+		}
+		if lines = append(lines, line); len(lines) == sniffLines {
+			break
+		}
+	}
+	if len(lines) == 0 {
+		return 0, errors.New("-sep auto: no measurement line to detect the separator from")
+	}
+
+	var found []byte
+	for _, sep := range []byte(sniffSeps) {
+		consistent := true
+		for _, line := range lines {
+			if bytes.Count(line, []byte{sep}) != 1 {
+				consistent = false
+				break
+			}
+		}
+		if consistent {
+			found = append(found, sep)
+		}
+	}
+	switch len(found) {
+	case 1:
+		return found[0], nil
+	case 0:
+		return 0, fmt.Errorf("-sep auto: no separator of %q occurs exactly once on every line", sniffSeps)
+	default:
+		return 0, fmt.Errorf("-sep auto: ambiguous separator, each of %q occurs exactly once on every line", found)
+	}
+}
+
+// sniffFileSeparator runs autodetectSeparator on the start of the file at path.
+func sniffFileSeparator(path string) (byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("could not open file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	sample, err := io.ReadAll(io.LimitReader(file, sniffBytes))
+	if err != nil {
+		return 0, fmt.Errorf("could not read file: %w", err)
+	}
+	return autodetectSeparator(sample)
+}
+
+// sniffReaderSeparator runs autodetectSeparator on the start of r without consuming it: the
+// returned reader yields all of r's data, including the sniffed sample.
+func sniffReaderSeparator(r io.Reader) (byte, io.Reader, error) {
+	buffered := bufio.NewReaderSize(r, sniffBytes)
+	sample, err := buffered.Peek(sniffBytes)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+		return 0, nil, fmt.Errorf("could not read input: %w", err)
+	}
+	sep, err := autodetectSeparator(sample)
+	return sep, buffered, err
+}
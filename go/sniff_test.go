@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestAutodetectSeparator tests sniffing the separator of semicolon, comma and tab files,
+// skipping comments and a cut-off last line, and failing on ambiguous or unknown input.
+func TestAutodetectSeparator(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample string
+		want   byte
+		err    string
+	}{
+		{"semicolon", "Hamburg;12.0\nWashington, D.C.;20.0\nOslo;-5.0\n", ';', ""},
+		{"tab", "# station\ttemp\n\nHamburg\t12.0\r\nBerlin\t20.0\r\n", '\t', ""},
+		{"comma", "Hamburg,12.0\nBerlin,20.0\nOs", ',', ""},
+		{"single unterminated line", "Hamburg;12.0", ';', ""},
+		{"ambiguous", "Ham,burg;12.0\nBer,lin;20.0\n", 0, "ambiguous separator"},
+		{"none", "Hamburg 12.0\nBerlin 20.0\n", 0, "occurs exactly once on every line"},
+		{"only comments", "# nothing here\n", 0, "no measurement line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := autodetectSeparator([]byte(tt.sample))
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_SepAuto tests -sep auto on a tab-separated file, on stdin and on an ambiguous file.
+func TestRun_SepAuto(t *testing.T) {
+	dir := t.TempDir()
+	tabs := filepath.Join(dir, "tabs.tsv")
+	require.NoError(t, os.WriteFile(tabs, []byte("Hamburg\t12.0\nBerlin\t20.0\nHamburg\t8.0\n"), 0o600))
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-sep", "auto", tabs}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n\n", stdout.String())
+
+	stdout.Reset()
+	input := strings.NewReader("Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\n")
+	require.NoError(t, run([]string{"-sep", "auto", stdinPath}, input, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n\n", stdout.String(), "the sniffed sample is still processed")
+
+	ambiguous := filepath.Join(dir, "ambiguous.txt")
+	require.NoError(t, os.WriteFile(ambiguous, []byte("Ham,burg;12.0\n"), 0o600))
+	err := run([]string{"-sep", "auto", ambiguous}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-sep auto: ambiguous separator")
+}
+
+// TestRun_SepAutoStdin tests that -sep auto sniffs stdin behind the -read-timeout guard, so a
+// stalled producer still times out, and after the gzip decompression.
+func TestRun_SepAutoStdin(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer func() { _ = pw.Close() }()
+	go func() { _, _ = pw.Write([]byte("Hamburg;12.0\n")) }() // then stalls
+
+	done := make(chan error, 1)
+	go func() {
+		done <- run([]string{"-read-timeout", "50ms", "-sep", "auto", stdinPath}, pr, &bytes.Buffer{}, &bytes.Buffer{})
+	}()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, errReadTimeout)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run didn't time out")
+	}
+
+	compressed := filepath.Join(t.TempDir(), "tabs.txt.gz")
+	writeGzip(t, compressed, "Hamburg\t12.0\nBerlin\t20.0\n")
+	data, err := os.ReadFile(compressed)
+	require.NoError(t, err)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-sep", "auto", stdinPath}, bytes.NewReader(data), &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=12.0/12.0/12.0}\n\n", stdout.String())
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// groupAggregator aggregates input whose lines are grouped by station in ascending name
+// order (-sorted-input). Only the current group's tuple is kept instead of a map: when a
+// new station starts, the finished group is summarized and handed to emit right away.
+type groupAggregator struct {
+	station string     // station of the current group
+	tup     [4]float64 // [min, sum, count, max] of the current group
+	emit    func(StationStat) error
+}
+
+// newGroupAggregator creates a groupAggregator that passes every finished group to emit.
+func newGroupAggregator(emit func(StationStat) error) *groupAggregator {
+	return &groupAggregator{emit: emit}
+}
+
+// add aggregates a reading into the current group, emitting the previous group first if
+// the station changed. It fails if the station sorts before the current one, i.e. the
+// input isn't grouped in ascending order.
+func (g *groupAggregator) add(station string, temperature float64) error {
+	if g.tup[2] == 0 || station != g.station {
+		if g.tup[2] != 0 && station < g.station {
+			return fmt.Errorf("input is not sorted: station %q appears after %q", station, g.station)
+		}
+		if err := g.flush(); err != nil {
+			return err
+		}
+		g.station = station
+		g.tup = [4]float64{temperature, 0.0, 0.0, temperature}
+	}
+
+	g.tup[0] = math.Min(g.tup[0], temperature) // min
+	g.tup[1] += temperature                    // sum
+	g.tup[2] += 1.0                            // count
+	g.tup[3] = math.Max(g.tup[3], temperature) // max
+
+	return nil
+}
+
+// flush emits the current group, if any. It must be called once more after the last line.
+func (g *groupAggregator) flush() error {
+	if g.tup[2] == 0 {
+		return nil
+	}
+
+	stat := StationStat{
+		Name:  g.station,
+		Min:   g.tup[0],
+		Mean:  g.tup[1] / g.tup[2],
+		Max:   g.tup[3],
+		Count: int64(g.tup[2]),
+	}
+	g.tup = [4]float64{}
+
+	return g.emit(stat)
+}
+
+// groupWriter writes groups in the 1BRC `{station=min/mean/max, ...}` format as they are
+// emitted, so sorted input is printed without ever holding all stations in memory.
+type groupWriter struct {
+	w       io.Writer
+	written int
+}
+
+// write appends a station to the output, opening the brace before the first one.
+func (gw *groupWriter) write(stat StationStat) error {
+	prefix := ", "
+	if gw.written == 0 {
+		prefix = "{"
+	}
+	gw.written++
+
+	_, err := fmt.Fprintf(gw.w, "%s%s=%.1f/%.1f/%.1f", prefix, stat.Name, stat.Min, stat.Mean, stat.Max)
+	return err
+}
+
+// close terminates the output, producing `{}` if no station was written.
+func (gw *groupWriter) close() error {
+	closing := "}\n\n"
+	if gw.written == 0 {
+		closing = "{}\n\n"
+	}
+	_, err := io.WriteString(gw.w, closing)
+	return err
+}
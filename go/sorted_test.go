@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestGroupAggregator_Sorted tests per-group stats on input grouped in ascending order.
+func TestGroupAggregator_Sorted(t *testing.T) {
+	var emitted []StationStat
+	p := newProcessor(options{sortedInput: true})
+	p.groups = newGroupAggregator(func(stat StationStat) error {
+		emitted = append(emitted, stat)
+		return nil
+	})
+
+	input := "Berlin;20.0\nBerlin;25.0\nHamburg;12.0\nHamburg;8.0\nHamburg;10.0\nOslo;-5.0\n"
+	require.NoError(t, p.processReader(strings.NewReader(input)))
+	require.Len(t, emitted, 2) // Oslo is still the open group
+	require.NoError(t, p.groups.flush())
+
+	require.Equal(t, []StationStat{
+		{Name: "Berlin", Min: 20.0, Mean: 22.5, Max: 25.0, Count: 2},
+		{Name: "Hamburg", Min: 8.0, Mean: 10.0, Max: 12.0, Count: 3},
+		{Name: "Oslo", Min: -5.0, Mean: -5.0, Max: -5.0, Count: 1},
+	}, emitted)
+	require.Empty(t, p.stats)
+}
+
+// TestGroupAggregator_Disordered tests that a station reappearing out of order is an error.
+func TestGroupAggregator_Disordered(t *testing.T) {
+	g := newGroupAggregator(func(StationStat) error { return nil })
+
+	require.NoError(t, g.add("Berlin", 20.0))
+	require.NoError(t, g.add("Hamburg", 12.0))
+	err := g.add("Berlin", 25.0)
+	require.EqualError(t, err, `input is not sorted: station "Berlin" appears after "Hamburg"`)
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_SortedInput tests that -sorted-input prints the same output as the default path.
+func TestRun_SortedInput(t *testing.T) {
+	data := "Berlin;20.0\nBerlin;25.0\nHamburg;12.0\nHamburg;8.0\n"
+	file := createTestFile(t, data)
+	defer cleanupTestFile(t, file)
+
+	var sorted, regular bytes.Buffer
+	require.NoError(t, run([]string{"-sorted-input", file.Name()}, nil, &sorted, &bytes.Buffer{}))
+	require.NoError(t, run([]string{file.Name()}, nil, &regular, &bytes.Buffer{}))
+	require.Equal(t, regular.String(), sorted.String())
+
+	var empty bytes.Buffer
+	require.NoError(t, run([]string{"-sorted-input", "-"}, strings.NewReader(""), &empty, &bytes.Buffer{}))
+	require.Equal(t, "{}\n\n", empty.String())
+
+	err := run([]string{"-sorted-input", "-"}, strings.NewReader("Oslo;1.0\nBerlin;2.0\n"), &bytes.Buffer{}, &bytes.Buffer{})
+	require.Error(t, err)
+}
+
+// TestRun_SortedInputRejects tests that -sorted-input refuses the options it would ignore.
+func TestRun_SortedInputRejects(t *testing.T) {
+	file := createTestFile(t, "Berlin;20.0\nHamburg;12.0\n")
+	defer cleanupTestFile(t, file)
+	out := filepath.Join(t.TempDir(), "out.msgpack")
+
+	for _, args := range [][]string{
+		{"-format", "msgpack", "-o", out},
+		{"-format", "table"},
+		{"-sort", "mean"},
+		{"-sort-desc"},
+		{"-distinct"},
+		{"-mode"},
+		{"-by-sign"},
+		{"-kahan"},
+		{"-limit-stations", "1"},
+		{"-min-only"},
+		{"-summary"},
+		{"-append-output", filepath.Join(t.TempDir(), "stats.bin")},
+		{"-validate-sorted"},
+		{"-sorted-output-to-file", filepath.Join(t.TempDir(), "sorted.txt")},
+	} {
+		err := run(append(append([]string{"-sorted-input"}, args...), file.Name()), nil, &bytes.Buffer{}, &bytes.Buffer{})
+		require.ErrorContains(t, err, "-sorted-input can't be combined with", args)
+	}
+	require.NoFileExists(t, out)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "modernc.org/sqlite" // registers the pure-Go "sqlite" driver
+)
+
+// writeSQLite exports the statistics to a `stations` table in the SQLite database at path,
+// replacing any previous export. Rows are inserted by name in a single transaction.
+func writeSQLite(path string, stats map[string][4]float64) (err error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return fmt.Errorf("could not open database: %w", err)
+	}
+	defer func() {
+		if closeErr := db.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("could not close database: %w", closeErr)
+		}
+	}()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	if _, err = tx.Exec(`DROP TABLE IF EXISTS stations`); err != nil {
+		return fmt.Errorf("could not drop stations table: %w", err)
+	}
+	if _, err = tx.Exec(`CREATE TABLE stations(name TEXT PRIMARY KEY, min REAL, mean REAL, max REAL, count INTEGER)`); err != nil {
+		return fmt.Errorf("could not create stations table: %w", err)
+	}
+
+	insert, err := tx.Prepare(`INSERT INTO stations(name, min, mean, max, count) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("could not prepare insert: %w", err)
+	}
+	defer func() { _ = insert.Close() }()
+
+	for _, s := range SortedStats(stats) {
+		if _, err = insert.Exec(s.Name, s.Min, s.Mean, s.Max, s.Count); err != nil {
+			return fmt.Errorf("could not insert station %q: %w", s.Name, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+	return nil
+}
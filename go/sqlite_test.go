@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestWriteSQLite tests that the exported rows can be queried back, and that a second export replaces the first.
+func TestWriteSQLite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.db")
+
+	require.NoError(t, writeSQLite(path, map[string][4]float64{"Stale": {1, 1, 1, 1}}))
+	require.NoError(t, writeSQLite(path, map[string][4]float64{
+		"Hamburg": {8, 20, 2, 12},
+		"Berlin":  {20, 45, 2, 25},
+	}))
+
+	require.Equal(t, []StationStat{
+		{Name: "Berlin", Min: 20, Mean: 22.5, Max: 25, Count: 2},
+		{Name: "Hamburg", Min: 8, Mean: 10, Max: 12, Count: 2},
+	}, queryStations(t, path))
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_SQLite tests -format sqlite -o.
+func TestRun_SQLite(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\n")
+	defer cleanupTestFile(t, file)
+	path := filepath.Join(t.TempDir(), "out.db")
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-format", "sqlite", "-o", path, file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Empty(t, stdout.String())
+	require.Equal(t, []StationStat{
+		{Name: "Berlin", Min: 20, Mean: 20, Max: 20, Count: 1},
+		{Name: "Hamburg", Min: 8, Mean: 10, Max: 12, Count: 2},
+	}, queryStations(t, path))
+
+	err := run([]string{"-format", "sqlite", file.Name()}, nil, &stdout, &bytes.Buffer{})
+	require.EqualError(t, err, "-format sqlite requires -o")
+}
+
+// -------------------------------------------- Test Helper Functions --------------------------------------------
+
+// queryStations reads the stations table back, ordered by name.
+func queryStations(t *testing.T, path string) []StationStat {
+	t.Helper()
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, db.Close()) }()
+
+	rows, err := db.Query(`SELECT name, min, mean, max, count FROM stations ORDER BY name`)
+	require.NoError(t, err)
+	defer func() { require.NoError(t, rows.Close()) }()
+
+	var got []StationStat
+	for rows.Next() {
+		var s StationStat
+		require.NoError(t, rows.Scan(&s.Name, &s.Min, &s.Mean, &s.Max, &s.Count))
+		got = append(got, s)
+	}
+	require.NoError(t, rows.Err())
+	return got
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// loadStationSet reads a file with one station name per line into a set. Empty lines are
+// ignored, and a trailing '\r' is stripped so files with CRLF line endings work.
+func loadStationSet(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open station list: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	set := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name := strings.TrimSuffix(scanner.Text(), "\r")
+		if name != "" {
+			set[name] = struct{}{}
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read station list: %w", err)
+	}
+	return set, nil
+}
+
+// keepStation reports whether lines of the station pass the -allowlist and -blocklist
+// filters. With -by-hour the name is matched without its `@HH` suffix.
+func (o *options) keepStation(key string) bool {
+	if o.allowlist == nil && o.blocklist == nil {
+		return true
+	}
+	if o.byHour {
+		key = key[:len(key)-len("@HH")]
+	}
+	if _, blocked := o.blocklist[key]; blocked {
+		return false
+	}
+	if o.allowlist == nil {
+		return true
+	}
+	_, allowed := o.allowlist[key]
+	return allowed
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestLoadStationSet tests that names are read one per line, skipping empty lines and CRs.
+func TestLoadStationSet(t *testing.T) {
+	path := writeStationList(t, "Berlin\r\n\nRio;de;Janeiro\n")
+
+	set, err := loadStationSet(path)
+	require.NoError(t, err)
+	require.Equal(t, map[string]struct{}{"Berlin": {}, "Rio;de;Janeiro": {}}, set)
+
+	_, err = loadStationSet(filepath.Join(t.TempDir(), "missing.txt"))
+	require.ErrorContains(t, err, "could not open station list")
+}
+
+// TestProcessLine_AllowlistByHour tests that -by-hour keys are matched by station name.
+func TestProcessLine_AllowlistByHour(t *testing.T) {
+	p := newProcessor(options{byHour: true, allowlist: map[string]struct{}{"Berlin": {}}})
+	require.NoError(t, p.processLine("Berlin;12.0;3600"))
+	require.NoError(t, p.processLine("Oslo;1.0;3600"))
+
+	require.Equal(t, "{Berlin@01=12.0/12.0/12.0}", formatOutput(p.stats))
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_Allowlist tests that only the allowed stations of a file are aggregated.
+func TestRun_Allowlist(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nOslo;-5.0\nRome;30.0\nHamburg;8.0\n")
+	defer cleanupTestFile(t, file)
+	allowlist := writeStationList(t, "Hamburg\nOslo\n")
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-allowlist", allowlist, file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Hamburg=8.0/10.0/12.0, Oslo=-5.0/-5.0/-5.0}\n\n", stdout.String())
+}
+
+// TestRun_Blocklist tests that a blocked station is dropped without affecting the others.
+func TestRun_Blocklist(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nOslo;-5.0\nHamburg;8.0\nBerlin;-99.9\n")
+	defer cleanupTestFile(t, file)
+	blocklist := writeStationList(t, "Berlin\n")
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-blocklist", blocklist, file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Hamburg=8.0/10.0/12.0, Oslo=-5.0/-5.0/-5.0}\n\n", stdout.String())
+
+	allowlist := writeStationList(t, "Berlin\nOslo\n")
+	stdout.Reset()
+	require.NoError(t, run([]string{"-allowlist", allowlist, "-blocklist", blocklist, file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Oslo=-5.0/-5.0/-5.0}\n\n", stdout.String(), "blocking wins over allowing")
+}
+
+// TestRun_EmitEmpty tests that -emit-empty outputs allowlisted stations without readings
+// as placeholders, without persisting them with -append-output, and only combines with the
+// text format.
+func TestRun_EmitEmpty(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nOslo;-5.0\nHamburg;8.0\n")
+	defer cleanupTestFile(t, file)
+	allowlist := writeStationList(t, "Hamburg\nBerlin\n")
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-allowlist", allowlist, "-emit-empty", "-validate-sorted", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=-/-/-, Hamburg=8.0/10.0/12.0}\n\n", stdout.String())
+
+	stdout.Reset()
+	require.NoError(t, run([]string{"-allowlist", allowlist, "-emit-empty", "-distinct", "-max-only", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=-, Hamburg=12.0 distinct=2}\n\n", stdout.String())
+
+	intermediate := filepath.Join(t.TempDir(), "stats.txt")
+	require.NoError(t, run([]string{"-allowlist", allowlist, "-emit-empty", "-append-output", intermediate, file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{}))
+	data, err := os.ReadFile(intermediate)
+	require.NoError(t, err)
+	result, err := ReadIntermediate(bytes.NewReader(data))
+	require.NoError(t, err)
+	require.Equal(t, Result{"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0}}, result, "placeholders aren't persisted")
+
+	err = run([]string{"-emit-empty", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-emit-empty requires -allowlist")
+}
+
+// TestRun_DumpStations tests that -dump-stations prints the deduplicated sorted names, in a
+// form -allowlist reads back.
+func TestRun_DumpStations(t *testing.T) {
+	file := createTestFile(t, "Oslo;1.0\nBerlin;2.0\nOslo;3.0\nAbha;4.0\nBerlin;5.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-dump-stations", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "Abha\nBerlin\nOslo\n", stdout.String())
+
+	allowlist := writeStationList(t, stdout.String())
+	stdout.Reset()
+	require.NoError(t, run([]string{"-allowlist", allowlist, file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Abha=4.0/4.0/4.0, Berlin=2.0/3.5/5.0, Oslo=1.0/2.0/3.0}\n\n", stdout.String())
+
+	err := run([]string{"-dump-stations", "-summary", file.Name()}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "-dump-stations can't be combined with")
+}
+
+// -------------------------------------------- Test Helper Functions --------------------------------------------
+
+// writeStationList writes a station list file with the given content and returns its path.
+func writeStationList(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "stations.txt")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
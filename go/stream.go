@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// stdinPath is the file path argument that selects the streaming path over stdin.
+const stdinPath = "-"
+
+// streamBufferSize is the size of the read buffer used on the streaming path.
+const streamBufferSize = 1 << 20
+
+// processReader reads newline-delimited measurements from r and aggregates them into p.stats.
+//
+// It is the streaming counterpart of processFile for inputs that can't be memory-mapped,
+// such as stdin or a network connection. A line longer than the read buffer is accumulated
+// across reads; with opts.maxLineBytes set, the read fails as soon as a line exceeds it
+// instead of growing the buffer unboundedly.
+//
+// With opts.maxBytes set, reading stops once that many bytes have been consumed. The line
+// the limit falls in is still read to its end, so only whole lines are ever aggregated.
+//
+// Like processFile, it accepts LF and CRLF line endings, and a final line without a newline,
+// warning when both endings are mixed.
+func (p *processor) processReader(r io.Reader) error {
+	phaseStart := time.Now()
+	reader := bufio.NewReaderSize(r, streamBufferSize)
+
+	var line []byte
+	var consumed int64
+	lf, crlf := 0, 0
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		consumed += int64(len(chunk))
+
+		length := len(line)
+		newline := length > 0 && line[length-1] == '\n'
+		if newline {
+			length--
+		}
+		if length > 0 && line[length-1] == '\r' && (newline || errors.Is(err, io.EOF)) {
+			length--
+			if newline {
+				crlf++
+			}
+		} else if newline {
+			lf++
+		}
+		if p.opts.maxLineBytes > 0 && length > p.opts.maxLineBytes {
+			return fmt.Errorf("line exceeds the maximum of %d bytes", p.opts.maxLineBytes)
+		}
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue // the line continues past the buffer, keep accumulating
+		}
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("could not read input: %w", err)
+		}
+
+		if length > 0 {
+			if lineErr := p.processLine(string(line[:length])); lineErr != nil {
+				return lineErr
+			}
+		}
+		line = line[:0]
+
+		if err != nil { // io.EOF
+			break
+		}
+		if p.opts.maxBytes > 0 && consumed >= p.opts.maxBytes {
+			p.logger.Debug("stopped reading at byte limit", "bytes", consumed)
+			break
+		}
+	}
+	if lf > 0 && crlf > 0 {
+		p.logger.Warn("mixed line endings", "lf", lf, "crlf", crlf)
+	}
+	p.logger.Info("scanned input", "phase", "scan", "duration", time.Since(phaseStart))
+	p.logger.Debug("scan details", "stations", len(p.stats))
+
+	return nil
+}
+
+// gzipExt is the extension of gzip-compressed measurement files.
+const gzipExt = ".txt.gz"
+
+// processGzipFile decompresses the gzip file at path on the fly and aggregates it through
+// the streaming path, since compressed data can't be memory-mapped.
+func (p *processor) processGzipFile(path string) error {
+	phaseStart := time.Now()
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	decompressed, err := gzip.NewReader(file)
+	if err != nil {
+		return fmt.Errorf("could not read gzip header: %w", err)
+	}
+	defer func() { _ = decompressed.Close() }()
+
+	if err = p.processReader(decompressed); err != nil {
+		return err
+	}
+	p.logger.Info("decompressed file", "phase", "gunzip", "path", path, "duration", time.Since(phaseStart))
+	return nil
+}
+
+// gzipMagic is the two bytes every gzip stream starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// processStdin aggregates stdin through the streaming path. Input starting with the gzip
+// magic bytes is decompressed transparently, as there is no file extension to go by. With
+// p.sniffSep set, the separator is detected from the start of the decompressed data.
+func (p *processor) processStdin(stdin io.Reader) error {
+	r, compressed, err := maybeGunzip(p.withReadTimeout(stdin))
+	if err != nil {
+		return err
+	}
+	if compressed {
+		p.logger.Info("decompressing stdin", "phase", "gunzip")
+	}
+	if p.sniffSep {
+		if p.opts.sep, r, err = sniffReaderSeparator(r); err != nil {
+			return err
+		}
+		p.logger.Info("detected separator", "sep", string(p.opts.sep))
+	}
+	return p.processReader(r)
+}
+
+// maybeGunzip returns a reader over the decompressed data when r starts with the gzip magic
+// bytes, or over r's data unchanged otherwise, and reports which one it is.
+func maybeGunzip(r io.Reader) (io.Reader, bool, error) {
+	buffered := bufio.NewReaderSize(r, streamBufferSize)
+	magic, err := buffered.Peek(len(gzipMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, false, fmt.Errorf("could not read input: %w", err)
+	}
+	if !bytes.Equal(magic, gzipMagic) {
+		return buffered, false, nil
+	}
+
+	decompressed, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, false, fmt.Errorf("could not read gzip header: %w", err)
+	}
+	return decompressed, true, nil
+}
+
+// ProcessReaders aggregates the readers as one continuous stream of measurements. A reader
+// that doesn't end with a newline is terminated with one, so its last line isn't glued to
+// the first line of the next reader.
+func ProcessReaders(readers ...io.Reader) (Result, error) {
+	chained := make([]io.Reader, len(readers))
+	for i, r := range readers {
+		chained[i] = &newlineTerminatedReader{r: r}
+	}
+
+	p := newProcessor(options{})
+	if err := p.processReader(io.MultiReader(chained...)); err != nil {
+		return nil, err
+	}
+	return FromMap(p.stats), nil
+}
+
+// newlineTerminatedReader passes r through, appending a '\n' at EOF unless r is empty or
+// already ends with one.
+type newlineTerminatedReader struct {
+	r    io.Reader
+	last byte // last byte returned, 0 before the first one
+	eof  bool // r is exhausted
+}
+
+func (n *newlineTerminatedReader) Read(b []byte) (int, error) {
+	if !n.eof {
+		read, err := n.r.Read(b)
+		if read > 0 {
+			n.last = b[read-1]
+		}
+		if !errors.Is(err, io.EOF) {
+			return read, err
+		}
+		n.eof = true
+		if read > 0 {
+			return read, nil // the newline, if needed, follows on the next call
+		}
+	}
+
+	if n.last != 0 && n.last != '\n' && len(b) > 0 {
+		b[0] = '\n'
+		n.last = '\n'
+		return 1, nil
+	}
+	return 0, io.EOF
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestProcessReader_Lines tests the streaming path over a reader with and without a trailing newline.
+func TestProcessReader_Lines(t *testing.T) {
+	p := newProcessor(options{})
+	require.NoError(t, p.processReader(strings.NewReader("Hamburg;12.0\nBerlin;20.0\n\nHamburg;8.0")))
+
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}", formatOutput(p.stats))
+}
+
+// TestProcessReader_MaxLineBytes tests that a line longer than -max-line-bytes fails with a clear error.
+func TestProcessReader_MaxLineBytes(t *testing.T) {
+	p := newProcessor(options{maxLineBytes: 64})
+	input := "Hamburg;12.0\n" + strings.Repeat("x", 100) + ";1.0\n"
+
+	err := p.processReader(strings.NewReader(input))
+	require.EqualError(t, err, "line exceeds the maximum of 64 bytes")
+}
+
+// TestProcessReader_MaxLineBytesAcrossBuffers tests the guard on a line spanning several buffer fills.
+func TestProcessReader_MaxLineBytesAcrossBuffers(t *testing.T) {
+	p := newProcessor(options{maxLineBytes: 2 * streamBufferSize})
+	input := strings.Repeat("x", 3*streamBufferSize) // no newline at all
+
+	err := p.processReader(strings.NewReader(input))
+	require.EqualError(t, err, "line exceeds the maximum of 2097152 bytes")
+}
+
+// TestProcessReader_MaxBytes tests that -max-bytes aggregates only the prefix of a long stream.
+func TestProcessReader_MaxBytes(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("Hamburg;12.0\n") // 13 bytes
+	input.WriteString("Berlin;20.0\n")  // 12 bytes, the 20-byte limit falls in this line
+	for i := 0; i < 10_000; i++ {
+		input.WriteString("Oslo;-5.0\n")
+	}
+
+	p := newProcessor(options{maxBytes: 20})
+	require.NoError(t, p.processReader(strings.NewReader(input.String())))
+
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Hamburg=12.0/12.0/12.0}", formatOutput(p.stats))
+}
+
+// TestProcessReaders tests that a reader without a trailing newline isn't glued to the next one.
+func TestProcessReaders(t *testing.T) {
+	result, err := ProcessReaders(
+		strings.NewReader("Hamburg;12.0\nBerlin;20.0"), // no trailing newline
+		strings.NewReader(""),
+		iotest.OneByteReader(strings.NewReader("Hamburg;8.0\nOslo;-5.0")),
+		strings.NewReader("Berlin;25.0\n"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, Result{
+		"Berlin":  {Min: 20.0, Sum: 45.0, Count: 2, Max: 25.0},
+		"Hamburg": {Min: 8.0, Sum: 20.0, Count: 2, Max: 12.0},
+		"Oslo":    {Min: -5.0, Sum: -5.0, Count: 1, Max: -5.0},
+	}, result)
+
+	_, err = ProcessReaders(strings.NewReader("Hamburg;12.0"), strings.NewReader("broken\n"))
+	require.EqualError(t, err, "could not parse line: broken")
+}
+
+// TestMaybeGunzip tests that gzip data is detected by its magic bytes and other input,
+// including input shorter than the magic, is passed through.
+func TestMaybeGunzip(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, err := zw.Write([]byte("Hamburg;12.0\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	for _, tc := range []struct {
+		input      []byte
+		want       string
+		compressed bool
+	}{
+		{compressed.Bytes(), "Hamburg;12.0\n", true},
+		{[]byte("Hamburg;12.0\n"), "Hamburg;12.0\n", false},
+		{[]byte{0x1f}, "\x1f", false},
+		{nil, "", false},
+	} {
+		r, isGzip, err := maybeGunzip(iotest.OneByteReader(bytes.NewReader(tc.input)))
+		require.NoError(t, err)
+		require.Equal(t, tc.compressed, isGzip)
+		data, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, string(data))
+	}
+
+	_, _, err = maybeGunzip(bytes.NewReader([]byte{0x1f, 0x8b, 0x00}))
+	require.ErrorContains(t, err, "could not read gzip header")
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_Stdin tests that "-" reads the measurements from stdin.
+func TestRun_Stdin(t *testing.T) {
+	var stdout bytes.Buffer
+	stdin := strings.NewReader("Oslo;-5.0\nOslo;-10.0\nOslo;-2.0\n")
+
+	require.NoError(t, run([]string{"-max-line-bytes", "32", "-"}, stdin, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Oslo=-10.0/-5.7/-2.0}\n\n", stdout.String())
+}
+
+// TestRun_StdinGzip tests that gzip-compressed stdin is decompressed transparently.
+func TestRun_StdinGzip(t *testing.T) {
+	var stdin bytes.Buffer
+	zw := gzip.NewWriter(&stdin)
+	_, err := zw.Write([]byte("Oslo;-5.0\nOslo;-10.0\nBerlin;20.0\n"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-"}, &stdin, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Berlin=20.0/20.0/20.0, Oslo=-10.0/-7.5/-5.0}\n\n", stdout.String())
+}
@@ -0,0 +1,41 @@
+package main
+
+import "math"
+
+// compensatedSum is a running sum using Neumaier's variant of Kahan summation.
+//
+// Adding a billion small readings to a large float64 sum loses low-order bits at every
+// step; the compensation term c collects those lost bits so value() stays within one
+// rounding error of the exact sum, regardless of the number of additions.
+type compensatedSum struct {
+	sum float64 // naive running sum
+	c   float64 // accumulated rounding error of sum
+}
+
+// add adds x to the sum.
+func (s *compensatedSum) add(x float64) {
+	t := s.sum + x
+	if math.Abs(s.sum) >= math.Abs(x) {
+		s.c += (s.sum - t) + x // low-order bits of x were lost
+	} else {
+		s.c += (x - t) + s.sum // low-order bits of sum were lost
+	}
+	s.sum = t
+}
+
+// value returns the compensated sum.
+func (s *compensatedSum) value() float64 {
+	return s.sum + s.c
+}
+
+// addCompensated adds temperature to the station's compensated sum, creating it on
+// first use, and returns the new compensated total.
+func (p *processor) addCompensated(station string, temperature float64) float64 {
+	s, exists := p.sums[station]
+	if !exists {
+		s = new(compensatedSum)
+		p.sums[station] = s
+	}
+	s.add(temperature)
+	return s.value()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestCompensatedSum tests that compensation recovers the bits naive summation loses.
+func TestCompensatedSum(t *testing.T) {
+	var s compensatedSum
+	naive := 0.0
+	for i := 0; i < 1_000_000; i++ {
+		s.add(0.1)
+		naive += 0.1
+	}
+
+	const exact = 100_000.0
+	require.Greater(t, math.Abs(naive-exact), 1e-6, "naive summation should visibly drift")
+	require.InDelta(t, exact, s.value(), 1e-9)
+
+	// Catastrophic cancellation: the 1.0 is lost entirely by naive summation.
+	s = compensatedSum{}
+	for _, x := range []float64{1.0, 1e100, 1.0, -1e100} {
+		s.add(x)
+	}
+	require.Equal(t, 2.0, s.value())
+}
+
+// TestProcessor_Kahan tests that -kahan sums are more accurate than the naive path.
+func TestProcessor_Kahan(t *testing.T) {
+	compensated := newProcessor(options{kahan: true})
+	naive := newProcessor(options{})
+	for i := 0; i < 1_000_000; i++ {
+		compensated.aggregate("Hamburg", 0.1)
+		naive.aggregate("Hamburg", 0.1)
+	}
+
+	const exact = 100_000.0
+	compensatedErr := math.Abs(compensated.stats["Hamburg"][1] - exact)
+	naiveErr := math.Abs(naive.stats["Hamburg"][1] - exact)
+	require.Less(t, compensatedErr, naiveErr)
+	require.InDelta(t, exact, compensated.stats["Hamburg"][1], 1e-9)
+	require.Equal(t, 1_000_000.0, compensated.stats["Hamburg"][2])
+}
+
+// TestProcessor_MergeKahan tests that merging keeps the compensated sums exact.
+func TestProcessor_MergeKahan(t *testing.T) {
+	a := newProcessor(options{kahan: true})
+	b := newProcessor(options{kahan: true})
+	for i := 0; i < 500_000; i++ {
+		a.aggregate("Hamburg", 0.1)
+		b.aggregate("Hamburg", 0.1)
+	}
+	b.aggregate("Berlin", 1.5)
+
+	a.merge(b)
+	require.InDelta(t, 100_000.0, a.stats["Hamburg"][1], 1e-9)
+	require.Equal(t, [4]float64{1.5, 1.5, 1.0, 1.5}, a.stats["Berlin"])
+}
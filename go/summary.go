@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Streams the -summary footer can be written to.
+const (
+	summaryToStdout = "stdout"
+	summaryToStderr = "stderr"
+)
+
+// formatSummary formats the -summary footer: the total records aggregated, the number of
+// stations, the global min and max across all stations with decimals digits after the
+// decimal point, and the elapsed wall-clock time.
+func formatSummary(stats map[string][4]float64, elapsed time.Duration, decimals int) string {
+	summary := FromMap(stats).Summary()
+	if summary.TotalReadings == 0 {
+		return fmt.Sprintf("summary: records=0 stations=%d elapsed=%s", summary.TotalStations, elapsed.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("summary: records=%d stations=%d min=%.*f max=%.*f elapsed=%s",
+		summary.TotalReadings, summary.TotalStations, decimals, summary.GlobalMin, decimals, summary.GlobalMax, elapsed.Round(time.Millisecond))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestFormatSummary tests the footer values for a known fixture and for no stations.
+func TestFormatSummary(t *testing.T) {
+	stats := map[string][4]float64{
+		"Hamburg": {8.0, 20.0, 2.0, 12.0},
+		"Berlin":  {20.0, 45.0, 2.0, 25.0},
+		"Oslo":    {-5.0, -5.0, 1.0, -5.0},
+	}
+	require.Equal(t, "summary: records=5 stations=3 min=-5.0 max=25.0 elapsed=1.5s", formatSummary(stats, 1500*time.Millisecond, 1))
+	require.Equal(t, "summary: records=0 stations=0 elapsed=0s", formatSummary(nil, 0, 1))
+
+	stats["Rome"] = [4]float64{} // a -emit-empty placeholder
+	require.Equal(t, "summary: records=5 stations=4 min=-5.0 max=25.0 elapsed=1.5s", formatSummary(stats, 1500*time.Millisecond, 1))
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_Summary tests that -summary follows the results on stdout, or goes to stderr with -summary-to.
+func TestRun_Summary(t *testing.T) {
+	file := createTestFile(t, "Hamburg;12.0\nBerlin;20.0\nHamburg;8.0\n")
+	defer cleanupTestFile(t, file)
+	footer := regexp.MustCompile(`^summary: records=3 stations=2 min=8\.0 max=20\.0 elapsed=\S+\n$`)
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"-summary", file.Name()}, nil, &stdout, &stderr))
+	results := "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n\n"
+	require.Equal(t, results, stdout.String()[:len(results)])
+	require.Regexp(t, footer, stdout.String()[len(results):])
+
+	stdout.Reset()
+	require.NoError(t, run([]string{"-summary", "-summary-to", "stderr", file.Name()}, nil, &stdout, &stderr))
+	require.Equal(t, results, stdout.String())
+	require.Regexp(t, footer, stderr.String())
+
+	err := run([]string{"-summary-to", "file", file.Name()}, nil, &stdout, &stderr)
+	require.EqualError(t, err, `-summary-to must be stdout or stderr, got "file"`)
+}
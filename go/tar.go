@@ -0,0 +1,76 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	inputTar = "tar"  // -format-in value for a tar archive of measurement files
+	tarExt   = ".tar" // extension selecting the tar input without -format-in
+)
+
+// isTarInput reports whether the input at path is read as a tar archive: with -format-in tar,
+// or for a file with the tar extension.
+func (c *config) isTarInput() bool {
+	return c.tarInput || (c.filePath != stdinPath && strings.HasSuffix(c.filePath, tarExt))
+}
+
+// processTarFile aggregates every measurement file in the tar archive at path.
+func (p *processor) processTarFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+	return p.processTar(file)
+}
+
+// processTar aggregates the regular *.txt and *.txt.gz members of the tar archive read from r
+// through the streaming path, one after the other. Directories, links and other members are
+// skipped, like the other files of a directory input.
+func (p *processor) processTar(r io.Reader) error {
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read tar archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg || !isMeasurementFile(header.Name) {
+			p.logger.Debug("skipping tar member", "member", header.Name, "type", string(header.Typeflag))
+			continue
+		}
+		if err = p.processTarMember(archive, header.Name); err != nil {
+			return fmt.Errorf("%s: %w", header.Name, err)
+		}
+	}
+}
+
+// processTarMember aggregates the current member of archive, decompressing a *.txt.gz one.
+func (p *processor) processTarMember(archive *tar.Reader, name string) error {
+	phaseStart := time.Now()
+	lines := p.lines
+	var member io.Reader = archive
+	if strings.HasSuffix(name, gzipExt) {
+		decompressed, err := gzip.NewReader(archive)
+		if err != nil {
+			return fmt.Errorf("could not read gzip header: %w", err)
+		}
+		defer func() { _ = decompressed.Close() }()
+		member = decompressed
+	}
+	if err := p.processReader(member); err != nil {
+		return err
+	}
+	p.logger.Info("processed tar member", "member", name, "lines", p.lines-lines, "duration", time.Since(phaseStart))
+	return nil
+}
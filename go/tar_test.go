@@ -0,0 +1,97 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestProcessTar tests that the measurement members of an in-memory archive are merged, and
+// that directories, links and other files are skipped.
+func TestProcessTar(t *testing.T) {
+	archive := buildTar(t, map[string]string{
+		"shards/":          "",
+		"shards/a.txt":     "Hamburg;12.0\nBerlin;20.0\n",
+		"shards/b.txt":     "Hamburg;8.0\nBerlin;25.0", // no trailing newline before the next member
+		"shards/README.md": "not measurements",
+		"shards/c.txt.gz":  "Oslo;-5.0\n",
+		"shards/link.txt":  "shards/a.txt",
+	})
+
+	p := newProcessor(options{})
+	require.NoError(t, p.processTar(bytes.NewReader(archive)))
+	require.Equal(t, "{Berlin=20.0/22.5/25.0, Hamburg=8.0/10.0/12.0, Oslo=-5.0/-5.0/-5.0}", formatOutput(p.stats))
+	require.Equal(t, int64(5), p.lines)
+
+	err := newProcessor(options{}).processTar(bytes.NewReader(buildTar(t, map[string]string{"bad.txt": "garbage\n"})))
+	require.ErrorContains(t, err, "bad.txt: could not parse line: garbage")
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_Tar tests tar input selected by the extension, by -format-in tar on stdin, and the
+// options it can't be combined with.
+func TestRun_Tar(t *testing.T) {
+	archive := buildTar(t, map[string]string{
+		"a.txt": "Hamburg;12.0\nBerlin;20.0\n",
+		"b.txt": "Hamburg;8.0\n",
+	})
+	path := filepath.Join(t.TempDir(), "shards.tar")
+	require.NoError(t, os.WriteFile(path, archive, 0o600))
+	const want = "{Berlin=20.0/20.0/20.0, Hamburg=8.0/10.0/12.0}\n\n"
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{path}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, want, stdout.String())
+
+	stdout.Reset()
+	require.NoError(t, run([]string{"-format-in", "tar", stdinPath}, bytes.NewReader(archive), &stdout, &bytes.Buffer{}))
+	require.Equal(t, want, stdout.String())
+
+	err := run([]string{"-workers", "2", path}, nil, &bytes.Buffer{}, &bytes.Buffer{})
+	require.ErrorContains(t, err, "tar input can't be combined with -workers")
+}
+
+// -------------------------------------------- Test Helper Functions --------------------------------------------
+
+// buildTar returns an archive of the given members in name order: names ending in '/' are
+// directories, *.gz members are gzip-compressed and members named link.txt are symlinks to
+// their content.
+func buildTar(t *testing.T, members map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range slices.Sorted(maps.Keys(members)) {
+		content := members[name]
+		switch {
+		case strings.HasSuffix(name, "/"):
+			require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0o700}))
+			continue
+		case filepath.Base(name) == "link.txt":
+			require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: name, Linkname: content, Mode: 0o600}))
+			continue
+		case strings.HasSuffix(name, ".gz"):
+			var compressed bytes.Buffer
+			zw := gzip.NewWriter(&compressed)
+			_, err := zw.Write([]byte(content))
+			require.NoError(t, err)
+			require.NoError(t, zw.Close())
+			content = compressed.String()
+		}
+		require.NoError(t, tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0o600, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// errReadTimeout is wrapped by the error of a read that got no data within -read-timeout.
+var errReadTimeout = errors.New("read timeout")
+
+// withReadTimeout returns r guarded by opts.readTimeout, or r itself when it isn't set.
+func (p *processor) withReadTimeout(r io.Reader) io.Reader {
+	if p.opts.readTimeout <= 0 {
+		return r
+	}
+	return newTimeoutReader(r, p.opts.readTimeout)
+}
+
+// readDeadliner is implemented by net.Conn and by pollable files such as pipes.
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// newTimeoutReader wraps r so that a Read that gets no data within timeout fails with
+// errReadTimeout. Readers supporting read deadlines, like network connections, use them;
+// others are read by a goroutine the caller stops waiting for.
+func newTimeoutReader(r io.Reader, timeout time.Duration) io.Reader {
+	if d, ok := r.(readDeadliner); ok && d.SetReadDeadline(time.Time{}) == nil {
+		return &deadlineReader{r: r, d: d, timeout: timeout}
+	}
+	return &timerReader{r: r, timeout: timeout, results: make(chan readResult, 1)}
+}
+
+// deadlineReader sets a fresh read deadline before every Read.
+type deadlineReader struct {
+	r       io.Reader
+	d       readDeadliner
+	timeout time.Duration
+}
+
+func (t *deadlineReader) Read(b []byte) (int, error) {
+	if err := t.d.SetReadDeadline(time.Now().Add(t.timeout)); err != nil {
+		return 0, err
+	}
+	n, err := t.r.Read(b)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return n, fmt.Errorf("%w: no data received within %s", errReadTimeout, t.timeout)
+	}
+	return n, err
+}
+
+// readResult is the outcome of a timerReader's background Read.
+type readResult struct {
+	n   int
+	err error
+}
+
+// timerReader reads r from a goroutine into its own buffer and waits for the result at
+// most timeout. A Read that timed out leaves the goroutine blocked in r.Read, and a later
+// Read picks its result up, so no data is lost; the goroutine leaks if r never returns.
+type timerReader struct {
+	r        io.Reader
+	timeout  time.Duration
+	results  chan readResult
+	buf      []byte
+	pending  []byte // data read but not yet returned
+	err      error  // error of the read pending came from, returned after it
+	inFlight bool   // a goroutine is reading into buf
+}
+
+func (t *timerReader) Read(b []byte) (int, error) {
+	if len(t.pending) > 0 {
+		n := copy(b, t.pending)
+		t.pending = t.pending[n:]
+		return n, nil
+	}
+	if t.err != nil {
+		err := t.err
+		t.err = nil
+		return 0, err
+	}
+	if !t.inFlight {
+		if len(t.buf) < len(b) {
+			t.buf = make([]byte, len(b))
+		}
+		buf := t.buf[:len(b)]
+		t.inFlight = true
+		go func() {
+			n, err := t.r.Read(buf)
+			t.results <- readResult{n: n, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+	select {
+	case result := <-t.results:
+		t.inFlight = false
+		n := copy(b, t.buf[:result.n])
+		t.pending = t.buf[n:result.n]
+		if len(t.pending) > 0 { // b is smaller than the read that timed out
+			t.err = result.err
+			return n, nil
+		}
+		return n, result.err
+	case <-timer.C:
+		return 0, fmt.Errorf("%w: no data received within %s", errReadTimeout, t.timeout)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestTimeoutReader_Timer tests the goroutine-based reader: a stalled read times out, and
+// its data is still delivered, across small buffers, once it arrives.
+func TestTimeoutReader_Timer(t *testing.T) {
+	pr, pw := io.Pipe()
+	r := newTimeoutReader(pr, 20*time.Millisecond)
+	require.IsType(t, &timerReader{}, r)
+
+	b := make([]byte, 16)
+	_, err := r.Read(b)
+	require.ErrorIs(t, err, errReadTimeout)
+
+	go func() {
+		_, _ = pw.Write([]byte("Hamburg;12.0\n"))
+		_ = pw.Close()
+	}()
+	var got bytes.Buffer
+	for {
+		n, err := r.Read(b[:4])
+		got.Write(b[:n])
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+	}
+	require.Equal(t, "Hamburg;12.0\n", got.String())
+}
+
+// TestTimeoutReader_Deadline tests that a connection is guarded with read deadlines.
+func TestTimeoutReader_Deadline(t *testing.T) {
+	client, server := net.Pipe()
+	defer func() { _ = client.Close() }()
+	defer func() { _ = server.Close() }()
+
+	r := newTimeoutReader(server, 20*time.Millisecond)
+	require.IsType(t, &deadlineReader{}, r)
+
+	_, err := r.Read(make([]byte, 16))
+	require.ErrorIs(t, err, errReadTimeout)
+	require.ErrorContains(t, err, "no data received within 20ms")
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_ReadTimeout tests that a producer stalling after its first line makes a stdin
+// run fail with a timeout instead of hanging.
+func TestRun_ReadTimeout(t *testing.T) {
+	pr, pw := io.Pipe()
+	defer func() { _ = pw.Close() }()
+	go func() { _, _ = pw.Write([]byte("Hamburg;12.0\n")) }() // then stalls
+
+	done := make(chan error, 1)
+	go func() { done <- run([]string{"-read-timeout", "50ms", "-"}, pr, &bytes.Buffer{}, &bytes.Buffer{}) }()
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, errReadTimeout)
+		require.ErrorContains(t, err, "could not read input")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run didn't time out")
+	}
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-read-timeout", "1s", "-"}, strings.NewReader("Hamburg;12.0\n"), &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Hamburg=12.0/12.0/12.0}\n\n", stdout.String())
+}
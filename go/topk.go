@@ -0,0 +1,95 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+)
+
+// topK keeps only the K stations with the highest max, so memory stays O(K) however many
+// stations the input has. It is a min-heap on max plus an index from station to heap slot.
+//
+// The ranking is exact: a station belonging to the top K is never outranked by K others
+// when its max is seen, so it is admitted then and kept from there on. Its other metrics
+// are only approximate, since measurements seen before an earlier eviction are lost.
+type topK struct {
+	k       int
+	entries []topKEntry
+	index   map[string]int // station -> position in entries
+}
+
+// topKEntry is a tracked station and its [min, sum, count, max] tuple.
+type topKEntry struct {
+	station string
+	tup     [4]float64
+}
+
+// newTopK creates a topK tracking at most k stations.
+func newTopK(k int) *topK {
+	return &topK{k: k, index: make(map[string]int, k)}
+}
+
+// add records a single temperature for station.
+func (t *topK) add(station string, temperature float64) {
+	t.observe(station, [4]float64{temperature, temperature, 1, temperature})
+}
+
+// observe combines a [min, sum, count, max] tuple into station's entry, admitting the
+// station if it isn't tracked yet and its max beats the lowest tracked one.
+func (t *topK) observe(station string, tup [4]float64) {
+	if i, exists := t.index[station]; exists {
+		e := &t.entries[i]
+		e.tup = [4]float64{
+			math.Min(e.tup[0], tup[0]), // min
+			e.tup[1] + tup[1],          // sum
+			e.tup[2] + tup[2],          // count
+			math.Max(e.tup[3], tup[3]), // max
+		}
+		heap.Fix(t, i)
+		return
+	}
+
+	if len(t.entries) < t.k {
+		heap.Push(t, topKEntry{station: station, tup: tup})
+		return
+	}
+	if t.k == 0 || tup[3] <= t.entries[0].tup[3] {
+		return // not hotter than the coolest tracked station
+	}
+	delete(t.index, t.entries[0].station)
+	t.entries[0] = topKEntry{station: station, tup: tup}
+	t.index[station] = 0
+	heap.Fix(t, 0)
+}
+
+// stats returns the tracked stations in the usual stats map representation.
+func (t *topK) stats() map[string][4]float64 {
+	stats := make(map[string][4]float64, len(t.entries))
+	for _, e := range t.entries {
+		stats[e.station] = e.tup
+	}
+	return stats
+}
+
+// Len, Less, Swap, Push and Pop implement heap.Interface. Use the methods above instead.
+
+func (t *topK) Len() int           { return len(t.entries) }
+func (t *topK) Less(i, j int) bool { return t.entries[i].tup[3] < t.entries[j].tup[3] }
+
+func (t *topK) Swap(i, j int) {
+	t.entries[i], t.entries[j] = t.entries[j], t.entries[i]
+	t.index[t.entries[i].station] = i
+	t.index[t.entries[j].station] = j
+}
+
+func (t *topK) Push(x any) {
+	e := x.(topKEntry)
+	t.index[e.station] = len(t.entries)
+	t.entries = append(t.entries, e)
+}
+
+func (t *topK) Pop() any {
+	e := t.entries[len(t.entries)-1]
+	t.entries = t.entries[:len(t.entries)-1]
+	delete(t.index, e.station)
+	return e
+}
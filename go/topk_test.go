@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestTopK_Skewed tests that the tracked set is the exact top-K by max on a skewed input,
+// where hot stations show up late and cold ones dominate the line count.
+func TestTopK_Skewed(t *testing.T) {
+	rng := newRand(42)
+	top := newTopK(3)
+	full := newProcessor(options{})
+	add := func(station string, temperature float64) {
+		top.add(station, temperature)
+		full.aggregate(station, temperature)
+	}
+
+	for i := range 5000 {
+		station := fmt.Sprintf("cold%02d", rng.IntN(50))
+		add(station, float64(rng.IntN(200))/10-10) // -10.0..9.9
+		if i > 4000 && i%50 == 0 {
+			add(fmt.Sprintf("hot%d", i%7), float64(rng.IntN(500))/10+10) // 10.0..59.9
+		}
+	}
+
+	requireTopK(t, full.stats, top, 3)
+	for station := range top.stats() {
+		require.True(t, strings.HasPrefix(station, "hot"), "%s is in the top 3", station)
+	}
+}
+
+// TestTopK_Random tests the ranking against a full aggregation on random inputs.
+func TestTopK_Random(t *testing.T) {
+	rng := newRand(7)
+	for range 50 {
+		k := 1 + rng.IntN(5)
+		top := newTopK(k)
+		full := newProcessor(options{})
+		for range 500 {
+			station := fmt.Sprintf("s%02d", rng.IntN(20))
+			temperature := float64(rng.IntN(1999)-999) / 10
+			top.add(station, temperature)
+			full.aggregate(station, temperature)
+		}
+		requireTopK(t, full.stats, top, k)
+	}
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_TopK tests -top-k on a file, including the approximate metrics of an evicted station.
+func TestRun_TopK(t *testing.T) {
+	file := createTestFile(t, "Oslo;1.0\nBerlin;20.0\nHamburg;21.0\nOslo;30.0\nRome;25.0\n")
+	defer cleanupTestFile(t, file)
+
+	var stdout bytes.Buffer
+	require.NoError(t, run([]string{"-top-k", "2", file.Name()}, nil, &stdout, &bytes.Buffer{}))
+	require.Equal(t, "{Oslo=30.0/30.0/30.0, Rome=25.0/25.0/25.0}\n\n", stdout.String())
+
+	err := run([]string{"-top-k", "2", "-kahan", file.Name()}, nil, &stdout, &bytes.Buffer{})
+	require.EqualError(t, err, "-top-k can't be combined with -sorted-input, -distinct, -mode, -by-sign or -kahan")
+}
+
+// -------------------------------------------- Test Helper Functions --------------------------------------------
+
+// requireTopK checks that top tracks k stations whose exact maxes are the k highest of the
+// full aggregation. Comparing maxes rather than names keeps ties at the cut unambiguous.
+func requireTopK(t *testing.T, full map[string][4]float64, top *topK, k int) {
+	t.Helper()
+	maxes := func(stats map[string][4]float64) []float64 {
+		var values []float64
+		for _, tup := range stats {
+			values = append(values, tup[3])
+		}
+		sort.Sort(sort.Reverse(sort.Float64Slice(values)))
+		return values
+	}
+
+	tracked := top.stats()
+	require.Len(t, tracked, min(k, len(full)))
+	require.Equal(t, maxes(full)[:len(tracked)], maxes(tracked))
+	for station, tup := range tracked {
+		require.Equal(t, full[station][3], tup[3], "max of %s is exact", station)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// variance returns the population variance of a station's readings from its
+// [min, sum, count, max] tuple and the sum of their squares, as E[x²] - E[x]². Rounding
+// can push the difference of two nearly equal terms below zero, so it is clamped at zero.
+func variance(tup [4]float64, sumSquares float64) float64 {
+	mean := tup[1] / tup[2]
+	return math.Max(0, sumSquares/tup[2]-mean*mean)
+}
+
+// coefficientOfVariation returns the standard deviation relative to the magnitude of the
+// mean, and false when the mean is zero and the ratio would be infinite.
+func coefficientOfVariation(tup [4]float64, sumSquares float64) (float64, bool) {
+	mean := tup[1] / tup[2]
+	if mean == 0 {
+		return 0, false
+	}
+	return math.Sqrt(variance(tup, sumSquares)) / math.Abs(mean), true
+}
+
+// formatVariation formats the -cv fields of a station: its variance and coefficient of
+// variation, the latter "n/a" for a zero mean.
+func formatVariation(tup [4]float64, sumSquares float64) string {
+	cv, ok := coefficientOfVariation(tup, sumSquares)
+	if !ok {
+		return fmt.Sprintf(" variance=%.2f cv=n/a", variance(tup, sumSquares))
+	}
+	return fmt.Sprintf(" variance=%.2f cv=%.3f", variance(tup, sumSquares), cv)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// -------------------------------------------- Unit Tests --------------------------------------------
+
+// TestCoefficientOfVariation tests the variance and CV of a known dataset, a negative mean and
+// the zero-mean guard.
+func TestCoefficientOfVariation(t *testing.T) {
+	// 2, 4, 4, 4, 5, 5, 7, 9: mean 5, population variance 4, stddev 2.
+	tup, squares := [4]float64{2, 40, 8, 9}, 4.0+16+16+16+25+25+49+81
+	require.InDelta(t, 4.0, variance(tup, squares), 1e-9)
+	cv, ok := coefficientOfVariation(tup, squares)
+	require.True(t, ok)
+	require.InDelta(t, 0.4, cv, 1e-9)
+
+	// The same readings negated: the CV relates to the magnitude of the mean.
+	cv, ok = coefficientOfVariation([4]float64{-9, -40, 8, -2}, squares)
+	require.True(t, ok)
+	require.InDelta(t, 0.4, cv, 1e-9)
+
+	// -1 and 1: mean 0, variance 1.
+	_, ok = coefficientOfVariation([4]float64{-1, 0, 2, 1}, 2)
+	require.False(t, ok)
+	require.Equal(t, " variance=1.00 cv=n/a", formatVariation([4]float64{-1, 0, 2, 1}, 2))
+
+	// Constant stations: no variance up to rounding, which never makes it negative.
+	for _, temperature := range []float64{0.1, 0.7, 12.3, -45.6, 99.9} {
+		got := variance([4]float64{temperature, 3 * temperature, 3, temperature}, 3*temperature*temperature)
+		require.GreaterOrEqual(t, got, 0.0)
+		require.InDelta(t, 0.0, got, 1e-9)
+	}
+}
+
+// -------------------------------------------- Integration Tests --------------------------------------------
+
+// TestRun_CV tests that -cv appends each station's variance and coefficient of variation,
+// across merged chunks, with n/a for a zero mean.
+func TestRun_CV(t *testing.T) {
+	var input strings.Builder
+	for _, temperature := range []string{"2.0", "4.0", "4.0", "4.0", "5.0", "5.0", "7.0", "9.0"} {
+		input.WriteString("Berlin;" + temperature + "\nOslo;-1.0\nOslo;1.0\n")
+	}
+	file := createTestFile(t, input.String())
+	defer cleanupTestFile(t, file)
+
+	const want = "{Berlin=2.0/5.0/9.0 variance=4.00 cv=0.400, Oslo=-1.0/0.0/1.0 variance=1.00 cv=n/a}\n\n"
+	for _, args := range [][]string{{"-cv"}, {"-cv", "-workers", "2"}} {
+		var stdout bytes.Buffer
+		require.NoError(t, run(append(args, file.Name()), nil, &stdout, &bytes.Buffer{}))
+		require.Equal(t, want, stdout.String(), args)
+	}
+}